@@ -19,23 +19,51 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Executor_AppendCode_FullMethodName      = "/executor.Executor/AppendCode"
-	Executor_Plan_FullMethodName            = "/executor.Executor/Plan"
-	Executor_Apply_FullMethodName           = "/executor.Executor/Apply"
-	Executor_Destroy_FullMethodName         = "/executor.Executor/Destroy"
-	Executor_GetStateList_FullMethodName    = "/executor.Executor/GetStateList"
-	Executor_GetTFShow_FullMethodName       = "/executor.Executor/GetTFShow"
-	Executor_ClearCode_FullMethodName       = "/executor.Executor/ClearCode"
-	Executor_CreateProject_FullMethodName   = "/executor.Executor/CreateProject"
-	Executor_DeleteProject_FullMethodName   = "/executor.Executor/DeleteProject"
-	Executor_AddProviders_FullMethodName    = "/executor.Executor/AddProviders"
-	Executor_ClearProviders_FullMethodName  = "/executor.Executor/ClearProviders"
-	Executor_AddSecretEnv_FullMethodName    = "/executor.Executor/AddSecretEnv"
-	Executor_ClearSecretEnv_FullMethodName  = "/executor.Executor/ClearSecretEnv"
-	Executor_AddSecretVar_FullMethodName    = "/executor.Executor/AddSecretVar"
-	Executor_ClearSecretVars_FullMethodName = "/executor.Executor/ClearSecretVars"
-	Executor_GetMainTf_FullMethodName       = "/executor.Executor/GetMainTf"
-	Executor_StreamLogs_FullMethodName      = "/executor.Executor/StreamLogs"
+	Executor_AppendCode_FullMethodName        = "/executor.Executor/AppendCode"
+	Executor_Plan_FullMethodName              = "/executor.Executor/Plan"
+	Executor_Apply_FullMethodName             = "/executor.Executor/Apply"
+	Executor_Destroy_FullMethodName           = "/executor.Executor/Destroy"
+	Executor_Validate_FullMethodName          = "/executor.Executor/Validate"
+	Executor_ListBlocks_FullMethodName        = "/executor.Executor/ListBlocks"
+	Executor_GetBlock_FullMethodName          = "/executor.Executor/GetBlock"
+	Executor_UpsertBlock_FullMethodName       = "/executor.Executor/UpsertBlock"
+	Executor_RemoveBlock_FullMethodName       = "/executor.Executor/RemoveBlock"
+	Executor_PutFile_FullMethodName           = "/executor.Executor/PutFile"
+	Executor_GetFile_FullMethodName           = "/executor.Executor/GetFile"
+	Executor_ListFiles_FullMethodName         = "/executor.Executor/ListFiles"
+	Executor_DeleteFile_FullMethodName        = "/executor.Executor/DeleteFile"
+	Executor_UploadArchive_FullMethodName     = "/executor.Executor/UploadArchive"
+	Executor_DownloadArchive_FullMethodName   = "/executor.Executor/DownloadArchive"
+	Executor_BindGit_FullMethodName           = "/executor.Executor/BindGit"
+	Executor_GetGitBinding_FullMethodName     = "/executor.Executor/GetGitBinding"
+	Executor_UnbindGit_FullMethodName         = "/executor.Executor/UnbindGit"
+	Executor_SyncFromGit_FullMethodName       = "/executor.Executor/SyncFromGit"
+	Executor_GetStateList_FullMethodName      = "/executor.Executor/GetStateList"
+	Executor_GetTFShow_FullMethodName         = "/executor.Executor/GetTFShow"
+	Executor_ClearCode_FullMethodName         = "/executor.Executor/ClearCode"
+	Executor_CreateProject_FullMethodName     = "/executor.Executor/CreateProject"
+	Executor_DeleteProject_FullMethodName     = "/executor.Executor/DeleteProject"
+	Executor_AddProviders_FullMethodName      = "/executor.Executor/AddProviders"
+	Executor_ClearProviders_FullMethodName    = "/executor.Executor/ClearProviders"
+	Executor_AddSecretEnv_FullMethodName      = "/executor.Executor/AddSecretEnv"
+	Executor_ClearSecretEnv_FullMethodName    = "/executor.Executor/ClearSecretEnv"
+	Executor_AddSecretVar_FullMethodName      = "/executor.Executor/AddSecretVar"
+	Executor_ClearSecretVars_FullMethodName   = "/executor.Executor/ClearSecretVars"
+	Executor_SetVariables_FullMethodName      = "/executor.Executor/SetVariables"
+	Executor_ListVariables_FullMethodName     = "/executor.Executor/ListVariables"
+	Executor_GetMainTf_FullMethodName         = "/executor.Executor/GetMainTf"
+	Executor_SetRuntime_FullMethodName        = "/executor.Executor/SetRuntime"
+	Executor_GetRuntime_FullMethodName        = "/executor.Executor/GetRuntime"
+	Executor_ListRevisions_FullMethodName     = "/executor.Executor/ListRevisions"
+	Executor_DiffRevisions_FullMethodName     = "/executor.Executor/DiffRevisions"
+	Executor_RestoreRevision_FullMethodName   = "/executor.Executor/RestoreRevision"
+	Executor_StartRun_FullMethodName          = "/executor.Executor/StartRun"
+	Executor_GetRun_FullMethodName            = "/executor.Executor/GetRun"
+	Executor_GetRunLogs_FullMethodName        = "/executor.Executor/GetRunLogs"
+	Executor_ListRuns_FullMethodName          = "/executor.Executor/ListRuns"
+	Executor_CancelRun_FullMethodName         = "/executor.Executor/CancelRun"
+	Executor_GetSchedulerState_FullMethodName = "/executor.Executor/GetSchedulerState"
+	Executor_StreamLogs_FullMethodName        = "/executor.Executor/StreamLogs"
 )
 
 // ExecutorClient is the client API for Executor service.
@@ -52,6 +80,36 @@ type ExecutorClient interface {
 	Apply(ctx context.Context, in *ApplyRequest, opts ...grpc.CallOption) (*ApplyResponse, error)
 	// Destroys the Terraform-managed infrastructure and returns the result.
 	Destroy(ctx context.Context, in *DestroyRequest, opts ...grpc.CallOption) (*DestroyResponse, error)
+	// Checks the Terraform configuration without credentials or backend.
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error)
+	// Lists the top-level blocks of main.tf.
+	ListBlocks(ctx context.Context, in *ListBlocksRequest, opts ...grpc.CallOption) (*ListBlocksResponse, error)
+	// Gets a block of main.tf by its address.
+	GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*GetBlockResponse, error)
+	// Replaces the block of main.tf with the same address, or appends it.
+	UpsertBlock(ctx context.Context, in *UpsertBlockRequest, opts ...grpc.CallOption) (*UpsertBlockResponse, error)
+	// Removes a block of main.tf by its address.
+	RemoveBlock(ctx context.Context, in *RemoveBlockRequest, opts ...grpc.CallOption) (*RemoveBlockResponse, error)
+	// Creates or replaces a file of the project.
+	PutFile(ctx context.Context, in *PutFileRequest, opts ...grpc.CallOption) (*PutFileResponse, error)
+	// Gets a file of the project.
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*GetFileResponse, error)
+	// Lists the files of the project.
+	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error)
+	// Deletes a file of the project.
+	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error)
+	// Replaces all files of the project with the files of a tar.gz or zip archive.
+	UploadArchive(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadArchiveRequest, UploadArchiveResponse], error)
+	// Downloads the files of the project as a tar.gz or zip archive.
+	DownloadArchive(ctx context.Context, in *DownloadArchiveRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadArchiveResponse], error)
+	// Binds the project to a git repository.
+	BindGit(ctx context.Context, in *BindGitRequest, opts ...grpc.CallOption) (*BindGitResponse, error)
+	// Gets the git repository of the project.
+	GetGitBinding(ctx context.Context, in *GetGitBindingRequest, opts ...grpc.CallOption) (*GetGitBindingResponse, error)
+	// Unbinds the project from its git repository.
+	UnbindGit(ctx context.Context, in *UnbindGitRequest, opts ...grpc.CallOption) (*UnbindGitResponse, error)
+	// Replaces the files of the project with the files of its git repository at the bound ref.
+	SyncFromGit(ctx context.Context, in *SyncFromGitRequest, opts ...grpc.CallOption) (*SyncFromGitResponse, error)
 	// Retrieves the Terraform state list.
 	GetStateList(ctx context.Context, in *GetStateListRequest, opts ...grpc.CallOption) (*GetStateListResponse, error)
 	// Retrieves the Terraform state file content.
@@ -74,9 +132,35 @@ type ExecutorClient interface {
 	AddSecretVar(ctx context.Context, in *AddSecretVarRequest, opts ...grpc.CallOption) (*AddSecretVarResponse, error)
 	// Clears the secret vars from the Terraform configuration.
 	ClearSecretVars(ctx context.Context, in *ClearSecretVarsRequest, opts ...grpc.CallOption) (*ClearSecretVarsResponse, error)
+	// Declares typed variables in variables.tf and stores their values.
+	SetVariables(ctx context.Context, in *SetVariablesRequest, opts ...grpc.CallOption) (*SetVariablesResponse, error)
+	// Lists the variables declared in variables.tf.
+	ListVariables(ctx context.Context, in *ListVariablesRequest, opts ...grpc.CallOption) (*ListVariablesResponse, error)
 	// Gets the content of main.tf file
 	GetMainTf(ctx context.Context, in *GetMainTfRequest, opts ...grpc.CallOption) (*GetMainTfResponse, error)
-	// Streams logs of a job in real time.
+	// Sets the engine, version and image digest the runs of a project execute with.
+	SetRuntime(ctx context.Context, in *SetRuntimeRequest, opts ...grpc.CallOption) (*SetRuntimeResponse, error)
+	// Gets the runtime of a project.
+	GetRuntime(ctx context.Context, in *GetRuntimeRequest, opts ...grpc.CallOption) (*GetRuntimeResponse, error)
+	// Lists the revisions of the project configuration.
+	ListRevisions(ctx context.Context, in *ListRevisionsRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error)
+	// Returns the unified diff of the project files between two revisions.
+	DiffRevisions(ctx context.Context, in *DiffRevisionsRequest, opts ...grpc.CallOption) (*DiffRevisionsResponse, error)
+	// Restores the project files of a revision as a new revision.
+	RestoreRevision(ctx context.Context, in *RestoreRevisionRequest, opts ...grpc.CallOption) (*RestoreRevisionResponse, error)
+	// Starts a Terraform operation in the background and returns its run ID.
+	StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*StartRunResponse, error)
+	// Gets the status and output of a run.
+	GetRun(ctx context.Context, in *GetRunRequest, opts ...grpc.CallOption) (*GetRunResponse, error)
+	// Gets a page of the archived logs or artifacts of a run.
+	GetRunLogs(ctx context.Context, in *GetRunLogsRequest, opts ...grpc.CallOption) (*GetRunLogsResponse, error)
+	// Lists the runs of a project.
+	ListRuns(ctx context.Context, in *ListRunsRequest, opts ...grpc.CallOption) (*ListRunsResponse, error)
+	// Cancels a run, letting terraform stop gracefully before its Job is deleted.
+	CancelRun(ctx context.Context, in *CancelRunRequest, opts ...grpc.CallOption) (*CancelRunResponse, error)
+	// Admin: gets the limits, active runner Jobs and queued runs of the scheduler.
+	GetSchedulerState(ctx context.Context, in *GetSchedulerStateRequest, opts ...grpc.CallOption) (*GetSchedulerStateResponse, error)
+	// Streams logs of jobs in real time, filtered by user, project, request or run.
 	StreamLogs(ctx context.Context, in *LogStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogStreamResponse], error)
 }
 
@@ -128,6 +212,168 @@ func (c *executorClient) Destroy(ctx context.Context, in *DestroyRequest, opts .
 	return out, nil
 }
 
+func (c *executorClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidateResponse)
+	err := c.cc.Invoke(ctx, Executor_Validate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) ListBlocks(ctx context.Context, in *ListBlocksRequest, opts ...grpc.CallOption) (*ListBlocksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBlocksResponse)
+	err := c.cc.Invoke(ctx, Executor_ListBlocks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetBlock(ctx context.Context, in *GetBlockRequest, opts ...grpc.CallOption) (*GetBlockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetBlockResponse)
+	err := c.cc.Invoke(ctx, Executor_GetBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) UpsertBlock(ctx context.Context, in *UpsertBlockRequest, opts ...grpc.CallOption) (*UpsertBlockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpsertBlockResponse)
+	err := c.cc.Invoke(ctx, Executor_UpsertBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) RemoveBlock(ctx context.Context, in *RemoveBlockRequest, opts ...grpc.CallOption) (*RemoveBlockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RemoveBlockResponse)
+	err := c.cc.Invoke(ctx, Executor_RemoveBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) PutFile(ctx context.Context, in *PutFileRequest, opts ...grpc.CallOption) (*PutFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PutFileResponse)
+	err := c.cc.Invoke(ctx, Executor_PutFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (*GetFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetFileResponse)
+	err := c.cc.Invoke(ctx, Executor_GetFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (*ListFilesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFilesResponse)
+	err := c.cc.Invoke(ctx, Executor_ListFiles_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*DeleteFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteFileResponse)
+	err := c.cc.Invoke(ctx, Executor_DeleteFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) UploadArchive(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadArchiveRequest, UploadArchiveResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Executor_ServiceDesc.Streams[0], Executor_UploadArchive_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadArchiveRequest, UploadArchiveResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Executor_UploadArchiveClient = grpc.ClientStreamingClient[UploadArchiveRequest, UploadArchiveResponse]
+
+func (c *executorClient) DownloadArchive(ctx context.Context, in *DownloadArchiveRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DownloadArchiveResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Executor_ServiceDesc.Streams[1], Executor_DownloadArchive_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DownloadArchiveRequest, DownloadArchiveResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Executor_DownloadArchiveClient = grpc.ServerStreamingClient[DownloadArchiveResponse]
+
+func (c *executorClient) BindGit(ctx context.Context, in *BindGitRequest, opts ...grpc.CallOption) (*BindGitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BindGitResponse)
+	err := c.cc.Invoke(ctx, Executor_BindGit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetGitBinding(ctx context.Context, in *GetGitBindingRequest, opts ...grpc.CallOption) (*GetGitBindingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetGitBindingResponse)
+	err := c.cc.Invoke(ctx, Executor_GetGitBinding_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) UnbindGit(ctx context.Context, in *UnbindGitRequest, opts ...grpc.CallOption) (*UnbindGitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UnbindGitResponse)
+	err := c.cc.Invoke(ctx, Executor_UnbindGit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) SyncFromGit(ctx context.Context, in *SyncFromGitRequest, opts ...grpc.CallOption) (*SyncFromGitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SyncFromGitResponse)
+	err := c.cc.Invoke(ctx, Executor_SyncFromGit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *executorClient) GetStateList(ctx context.Context, in *GetStateListRequest, opts ...grpc.CallOption) (*GetStateListResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetStateListResponse)
@@ -238,6 +484,26 @@ func (c *executorClient) ClearSecretVars(ctx context.Context, in *ClearSecretVar
 	return out, nil
 }
 
+func (c *executorClient) SetVariables(ctx context.Context, in *SetVariablesRequest, opts ...grpc.CallOption) (*SetVariablesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetVariablesResponse)
+	err := c.cc.Invoke(ctx, Executor_SetVariables_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) ListVariables(ctx context.Context, in *ListVariablesRequest, opts ...grpc.CallOption) (*ListVariablesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVariablesResponse)
+	err := c.cc.Invoke(ctx, Executor_ListVariables_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *executorClient) GetMainTf(ctx context.Context, in *GetMainTfRequest, opts ...grpc.CallOption) (*GetMainTfResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetMainTfResponse)
@@ -248,9 +514,119 @@ func (c *executorClient) GetMainTf(ctx context.Context, in *GetMainTfRequest, op
 	return out, nil
 }
 
+func (c *executorClient) SetRuntime(ctx context.Context, in *SetRuntimeRequest, opts ...grpc.CallOption) (*SetRuntimeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetRuntimeResponse)
+	err := c.cc.Invoke(ctx, Executor_SetRuntime_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetRuntime(ctx context.Context, in *GetRuntimeRequest, opts ...grpc.CallOption) (*GetRuntimeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRuntimeResponse)
+	err := c.cc.Invoke(ctx, Executor_GetRuntime_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) ListRevisions(ctx context.Context, in *ListRevisionsRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRevisionsResponse)
+	err := c.cc.Invoke(ctx, Executor_ListRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) DiffRevisions(ctx context.Context, in *DiffRevisionsRequest, opts ...grpc.CallOption) (*DiffRevisionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DiffRevisionsResponse)
+	err := c.cc.Invoke(ctx, Executor_DiffRevisions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) RestoreRevision(ctx context.Context, in *RestoreRevisionRequest, opts ...grpc.CallOption) (*RestoreRevisionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreRevisionResponse)
+	err := c.cc.Invoke(ctx, Executor_RestoreRevision_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*StartRunResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StartRunResponse)
+	err := c.cc.Invoke(ctx, Executor_StartRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetRun(ctx context.Context, in *GetRunRequest, opts ...grpc.CallOption) (*GetRunResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRunResponse)
+	err := c.cc.Invoke(ctx, Executor_GetRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetRunLogs(ctx context.Context, in *GetRunLogsRequest, opts ...grpc.CallOption) (*GetRunLogsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRunLogsResponse)
+	err := c.cc.Invoke(ctx, Executor_GetRunLogs_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) ListRuns(ctx context.Context, in *ListRunsRequest, opts ...grpc.CallOption) (*ListRunsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRunsResponse)
+	err := c.cc.Invoke(ctx, Executor_ListRuns_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) CancelRun(ctx context.Context, in *CancelRunRequest, opts ...grpc.CallOption) (*CancelRunResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelRunResponse)
+	err := c.cc.Invoke(ctx, Executor_CancelRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *executorClient) GetSchedulerState(ctx context.Context, in *GetSchedulerStateRequest, opts ...grpc.CallOption) (*GetSchedulerStateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSchedulerStateResponse)
+	err := c.cc.Invoke(ctx, Executor_GetSchedulerState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *executorClient) StreamLogs(ctx context.Context, in *LogStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[LogStreamResponse], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	stream, err := c.cc.NewStream(ctx, &Executor_ServiceDesc.Streams[0], Executor_StreamLogs_FullMethodName, cOpts...)
+	stream, err := c.cc.NewStream(ctx, &Executor_ServiceDesc.Streams[2], Executor_StreamLogs_FullMethodName, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -281,6 +657,36 @@ type ExecutorServer interface {
 	Apply(context.Context, *ApplyRequest) (*ApplyResponse, error)
 	// Destroys the Terraform-managed infrastructure and returns the result.
 	Destroy(context.Context, *DestroyRequest) (*DestroyResponse, error)
+	// Checks the Terraform configuration without credentials or backend.
+	Validate(context.Context, *ValidateRequest) (*ValidateResponse, error)
+	// Lists the top-level blocks of main.tf.
+	ListBlocks(context.Context, *ListBlocksRequest) (*ListBlocksResponse, error)
+	// Gets a block of main.tf by its address.
+	GetBlock(context.Context, *GetBlockRequest) (*GetBlockResponse, error)
+	// Replaces the block of main.tf with the same address, or appends it.
+	UpsertBlock(context.Context, *UpsertBlockRequest) (*UpsertBlockResponse, error)
+	// Removes a block of main.tf by its address.
+	RemoveBlock(context.Context, *RemoveBlockRequest) (*RemoveBlockResponse, error)
+	// Creates or replaces a file of the project.
+	PutFile(context.Context, *PutFileRequest) (*PutFileResponse, error)
+	// Gets a file of the project.
+	GetFile(context.Context, *GetFileRequest) (*GetFileResponse, error)
+	// Lists the files of the project.
+	ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error)
+	// Deletes a file of the project.
+	DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error)
+	// Replaces all files of the project with the files of a tar.gz or zip archive.
+	UploadArchive(grpc.ClientStreamingServer[UploadArchiveRequest, UploadArchiveResponse]) error
+	// Downloads the files of the project as a tar.gz or zip archive.
+	DownloadArchive(*DownloadArchiveRequest, grpc.ServerStreamingServer[DownloadArchiveResponse]) error
+	// Binds the project to a git repository.
+	BindGit(context.Context, *BindGitRequest) (*BindGitResponse, error)
+	// Gets the git repository of the project.
+	GetGitBinding(context.Context, *GetGitBindingRequest) (*GetGitBindingResponse, error)
+	// Unbinds the project from its git repository.
+	UnbindGit(context.Context, *UnbindGitRequest) (*UnbindGitResponse, error)
+	// Replaces the files of the project with the files of its git repository at the bound ref.
+	SyncFromGit(context.Context, *SyncFromGitRequest) (*SyncFromGitResponse, error)
 	// Retrieves the Terraform state list.
 	GetStateList(context.Context, *GetStateListRequest) (*GetStateListResponse, error)
 	// Retrieves the Terraform state file content.
@@ -303,9 +709,35 @@ type ExecutorServer interface {
 	AddSecretVar(context.Context, *AddSecretVarRequest) (*AddSecretVarResponse, error)
 	// Clears the secret vars from the Terraform configuration.
 	ClearSecretVars(context.Context, *ClearSecretVarsRequest) (*ClearSecretVarsResponse, error)
+	// Declares typed variables in variables.tf and stores their values.
+	SetVariables(context.Context, *SetVariablesRequest) (*SetVariablesResponse, error)
+	// Lists the variables declared in variables.tf.
+	ListVariables(context.Context, *ListVariablesRequest) (*ListVariablesResponse, error)
 	// Gets the content of main.tf file
 	GetMainTf(context.Context, *GetMainTfRequest) (*GetMainTfResponse, error)
-	// Streams logs of a job in real time.
+	// Sets the engine, version and image digest the runs of a project execute with.
+	SetRuntime(context.Context, *SetRuntimeRequest) (*SetRuntimeResponse, error)
+	// Gets the runtime of a project.
+	GetRuntime(context.Context, *GetRuntimeRequest) (*GetRuntimeResponse, error)
+	// Lists the revisions of the project configuration.
+	ListRevisions(context.Context, *ListRevisionsRequest) (*ListRevisionsResponse, error)
+	// Returns the unified diff of the project files between two revisions.
+	DiffRevisions(context.Context, *DiffRevisionsRequest) (*DiffRevisionsResponse, error)
+	// Restores the project files of a revision as a new revision.
+	RestoreRevision(context.Context, *RestoreRevisionRequest) (*RestoreRevisionResponse, error)
+	// Starts a Terraform operation in the background and returns its run ID.
+	StartRun(context.Context, *StartRunRequest) (*StartRunResponse, error)
+	// Gets the status and output of a run.
+	GetRun(context.Context, *GetRunRequest) (*GetRunResponse, error)
+	// Gets a page of the archived logs or artifacts of a run.
+	GetRunLogs(context.Context, *GetRunLogsRequest) (*GetRunLogsResponse, error)
+	// Lists the runs of a project.
+	ListRuns(context.Context, *ListRunsRequest) (*ListRunsResponse, error)
+	// Cancels a run, letting terraform stop gracefully before its Job is deleted.
+	CancelRun(context.Context, *CancelRunRequest) (*CancelRunResponse, error)
+	// Admin: gets the limits, active runner Jobs and queued runs of the scheduler.
+	GetSchedulerState(context.Context, *GetSchedulerStateRequest) (*GetSchedulerStateResponse, error)
+	// Streams logs of jobs in real time, filtered by user, project, request or run.
 	StreamLogs(*LogStreamRequest, grpc.ServerStreamingServer[LogStreamResponse]) error
 	mustEmbedUnimplementedExecutorServer()
 }
@@ -329,6 +761,51 @@ func (UnimplementedExecutorServer) Apply(context.Context, *ApplyRequest) (*Apply
 func (UnimplementedExecutorServer) Destroy(context.Context, *DestroyRequest) (*DestroyResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Destroy not implemented")
 }
+func (UnimplementedExecutorServer) Validate(context.Context, *ValidateRequest) (*ValidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedExecutorServer) ListBlocks(context.Context, *ListBlocksRequest) (*ListBlocksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBlocks not implemented")
+}
+func (UnimplementedExecutorServer) GetBlock(context.Context, *GetBlockRequest) (*GetBlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlock not implemented")
+}
+func (UnimplementedExecutorServer) UpsertBlock(context.Context, *UpsertBlockRequest) (*UpsertBlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertBlock not implemented")
+}
+func (UnimplementedExecutorServer) RemoveBlock(context.Context, *RemoveBlockRequest) (*RemoveBlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveBlock not implemented")
+}
+func (UnimplementedExecutorServer) PutFile(context.Context, *PutFileRequest) (*PutFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutFile not implemented")
+}
+func (UnimplementedExecutorServer) GetFile(context.Context, *GetFileRequest) (*GetFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFile not implemented")
+}
+func (UnimplementedExecutorServer) ListFiles(context.Context, *ListFilesRequest) (*ListFilesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFiles not implemented")
+}
+func (UnimplementedExecutorServer) DeleteFile(context.Context, *DeleteFileRequest) (*DeleteFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFile not implemented")
+}
+func (UnimplementedExecutorServer) UploadArchive(grpc.ClientStreamingServer[UploadArchiveRequest, UploadArchiveResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method UploadArchive not implemented")
+}
+func (UnimplementedExecutorServer) DownloadArchive(*DownloadArchiveRequest, grpc.ServerStreamingServer[DownloadArchiveResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadArchive not implemented")
+}
+func (UnimplementedExecutorServer) BindGit(context.Context, *BindGitRequest) (*BindGitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BindGit not implemented")
+}
+func (UnimplementedExecutorServer) GetGitBinding(context.Context, *GetGitBindingRequest) (*GetGitBindingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGitBinding not implemented")
+}
+func (UnimplementedExecutorServer) UnbindGit(context.Context, *UnbindGitRequest) (*UnbindGitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnbindGit not implemented")
+}
+func (UnimplementedExecutorServer) SyncFromGit(context.Context, *SyncFromGitRequest) (*SyncFromGitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SyncFromGit not implemented")
+}
 func (UnimplementedExecutorServer) GetStateList(context.Context, *GetStateListRequest) (*GetStateListResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStateList not implemented")
 }
@@ -362,9 +839,48 @@ func (UnimplementedExecutorServer) AddSecretVar(context.Context, *AddSecretVarRe
 func (UnimplementedExecutorServer) ClearSecretVars(context.Context, *ClearSecretVarsRequest) (*ClearSecretVarsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ClearSecretVars not implemented")
 }
+func (UnimplementedExecutorServer) SetVariables(context.Context, *SetVariablesRequest) (*SetVariablesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetVariables not implemented")
+}
+func (UnimplementedExecutorServer) ListVariables(context.Context, *ListVariablesRequest) (*ListVariablesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVariables not implemented")
+}
 func (UnimplementedExecutorServer) GetMainTf(context.Context, *GetMainTfRequest) (*GetMainTfResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetMainTf not implemented")
 }
+func (UnimplementedExecutorServer) SetRuntime(context.Context, *SetRuntimeRequest) (*SetRuntimeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRuntime not implemented")
+}
+func (UnimplementedExecutorServer) GetRuntime(context.Context, *GetRuntimeRequest) (*GetRuntimeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRuntime not implemented")
+}
+func (UnimplementedExecutorServer) ListRevisions(context.Context, *ListRevisionsRequest) (*ListRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRevisions not implemented")
+}
+func (UnimplementedExecutorServer) DiffRevisions(context.Context, *DiffRevisionsRequest) (*DiffRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DiffRevisions not implemented")
+}
+func (UnimplementedExecutorServer) RestoreRevision(context.Context, *RestoreRevisionRequest) (*RestoreRevisionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreRevision not implemented")
+}
+func (UnimplementedExecutorServer) StartRun(context.Context, *StartRunRequest) (*StartRunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRun not implemented")
+}
+func (UnimplementedExecutorServer) GetRun(context.Context, *GetRunRequest) (*GetRunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRun not implemented")
+}
+func (UnimplementedExecutorServer) GetRunLogs(context.Context, *GetRunLogsRequest) (*GetRunLogsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRunLogs not implemented")
+}
+func (UnimplementedExecutorServer) ListRuns(context.Context, *ListRunsRequest) (*ListRunsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRuns not implemented")
+}
+func (UnimplementedExecutorServer) CancelRun(context.Context, *CancelRunRequest) (*CancelRunResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelRun not implemented")
+}
+func (UnimplementedExecutorServer) GetSchedulerState(context.Context, *GetSchedulerStateRequest) (*GetSchedulerStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSchedulerState not implemented")
+}
 func (UnimplementedExecutorServer) StreamLogs(*LogStreamRequest, grpc.ServerStreamingServer[LogStreamResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
 }
@@ -461,80 +977,332 @@ func _Executor_Destroy_Handler(srv interface{}, ctx context.Context, dec func(in
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Executor_GetStateList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetStateListRequest)
+func _Executor_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ExecutorServer).GetStateList(ctx, in)
+		return srv.(ExecutorServer).Validate(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Executor_GetStateList_FullMethodName,
+		FullMethod: Executor_Validate_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ExecutorServer).GetStateList(ctx, req.(*GetStateListRequest))
+		return srv.(ExecutorServer).Validate(ctx, req.(*ValidateRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Executor_GetTFShow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetTFShowRequest)
+func _Executor_ListBlocks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBlocksRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ExecutorServer).GetTFShow(ctx, in)
+		return srv.(ExecutorServer).ListBlocks(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Executor_GetTFShow_FullMethodName,
+		FullMethod: Executor_ListBlocks_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ExecutorServer).GetTFShow(ctx, req.(*GetTFShowRequest))
+		return srv.(ExecutorServer).ListBlocks(ctx, req.(*ListBlocksRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Executor_ClearCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ClearCodeRequest)
+func _Executor_GetBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ExecutorServer).ClearCode(ctx, in)
+		return srv.(ExecutorServer).GetBlock(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Executor_ClearCode_FullMethodName,
+		FullMethod: Executor_GetBlock_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ExecutorServer).ClearCode(ctx, req.(*ClearCodeRequest))
+		return srv.(ExecutorServer).GetBlock(ctx, req.(*GetBlockRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Executor_CreateProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CreateProjectRequest)
+func _Executor_UpsertBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpsertBlockRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ExecutorServer).CreateProject(ctx, in)
+		return srv.(ExecutorServer).UpsertBlock(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: Executor_CreateProject_FullMethodName,
+		FullMethod: Executor_UpsertBlock_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ExecutorServer).CreateProject(ctx, req.(*CreateProjectRequest))
+		return srv.(ExecutorServer).UpsertBlock(ctx, req.(*UpsertBlockRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Executor_DeleteProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteProjectRequest)
+func _Executor_RemoveBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).RemoveBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_RemoveBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).RemoveBlock(ctx, req.(*RemoveBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_PutFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).PutFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_PutFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).PutFile(ctx, req.(*PutFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetFile(ctx, req.(*GetFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_ListFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).ListFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_ListFiles_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).ListFiles(ctx, req.(*ListFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).DeleteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_DeleteFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).DeleteFile(ctx, req.(*DeleteFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_UploadArchive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ExecutorServer).UploadArchive(&grpc.GenericServerStream[UploadArchiveRequest, UploadArchiveResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Executor_UploadArchiveServer = grpc.ClientStreamingServer[UploadArchiveRequest, UploadArchiveResponse]
+
+func _Executor_DownloadArchive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DownloadArchiveRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ExecutorServer).DownloadArchive(m, &grpc.GenericServerStream[DownloadArchiveRequest, DownloadArchiveResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Executor_DownloadArchiveServer = grpc.ServerStreamingServer[DownloadArchiveResponse]
+
+func _Executor_BindGit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BindGitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).BindGit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_BindGit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).BindGit(ctx, req.(*BindGitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetGitBinding_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGitBindingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetGitBinding(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetGitBinding_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetGitBinding(ctx, req.(*GetGitBindingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_UnbindGit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnbindGitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).UnbindGit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_UnbindGit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).UnbindGit(ctx, req.(*UnbindGitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_SyncFromGit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncFromGitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).SyncFromGit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_SyncFromGit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).SyncFromGit(ctx, req.(*SyncFromGitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetStateList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStateListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetStateList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetStateList_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetStateList(ctx, req.(*GetStateListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetTFShow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTFShowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetTFShow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetTFShow_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetTFShow(ctx, req.(*GetTFShowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_ClearCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).ClearCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_ClearCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).ClearCode(ctx, req.(*ClearCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_CreateProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateProjectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).CreateProject(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_CreateProject_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).CreateProject(ctx, req.(*CreateProjectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_DeleteProject_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProjectRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -659,6 +1427,42 @@ func _Executor_ClearSecretVars_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Executor_SetVariables_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetVariablesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).SetVariables(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_SetVariables_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).SetVariables(ctx, req.(*SetVariablesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_ListVariables_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVariablesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).ListVariables(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_ListVariables_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).ListVariables(ctx, req.(*ListVariablesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Executor_GetMainTf_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetMainTfRequest)
 	if err := dec(in); err != nil {
@@ -677,6 +1481,204 @@ func _Executor_GetMainTf_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Executor_SetRuntime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRuntimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).SetRuntime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_SetRuntime_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).SetRuntime(ctx, req.(*SetRuntimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetRuntime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuntimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetRuntime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetRuntime_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetRuntime(ctx, req.(*GetRuntimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_ListRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).ListRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_ListRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).ListRevisions(ctx, req.(*ListRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_DiffRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffRevisionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).DiffRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_DiffRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).DiffRevisions(ctx, req.(*DiffRevisionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_RestoreRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).RestoreRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_RestoreRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).RestoreRevision(ctx, req.(*RestoreRevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_StartRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).StartRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_StartRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).StartRun(ctx, req.(*StartRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetRun(ctx, req.(*GetRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetRunLogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRunLogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetRunLogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetRunLogs_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetRunLogs(ctx, req.(*GetRunLogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_ListRuns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRunsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).ListRuns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_ListRuns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).ListRuns(ctx, req.(*ListRunsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_CancelRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).CancelRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_CancelRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).CancelRun(ctx, req.(*CancelRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Executor_GetSchedulerState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSchedulerStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ExecutorServer).GetSchedulerState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Executor_GetSchedulerState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ExecutorServer).GetSchedulerState(ctx, req.(*GetSchedulerStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Executor_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(LogStreamRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -711,6 +1713,58 @@ var Executor_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Destroy",
 			Handler:    _Executor_Destroy_Handler,
 		},
+		{
+			MethodName: "Validate",
+			Handler:    _Executor_Validate_Handler,
+		},
+		{
+			MethodName: "ListBlocks",
+			Handler:    _Executor_ListBlocks_Handler,
+		},
+		{
+			MethodName: "GetBlock",
+			Handler:    _Executor_GetBlock_Handler,
+		},
+		{
+			MethodName: "UpsertBlock",
+			Handler:    _Executor_UpsertBlock_Handler,
+		},
+		{
+			MethodName: "RemoveBlock",
+			Handler:    _Executor_RemoveBlock_Handler,
+		},
+		{
+			MethodName: "PutFile",
+			Handler:    _Executor_PutFile_Handler,
+		},
+		{
+			MethodName: "GetFile",
+			Handler:    _Executor_GetFile_Handler,
+		},
+		{
+			MethodName: "ListFiles",
+			Handler:    _Executor_ListFiles_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _Executor_DeleteFile_Handler,
+		},
+		{
+			MethodName: "BindGit",
+			Handler:    _Executor_BindGit_Handler,
+		},
+		{
+			MethodName: "GetGitBinding",
+			Handler:    _Executor_GetGitBinding_Handler,
+		},
+		{
+			MethodName: "UnbindGit",
+			Handler:    _Executor_UnbindGit_Handler,
+		},
+		{
+			MethodName: "SyncFromGit",
+			Handler:    _Executor_SyncFromGit_Handler,
+		},
 		{
 			MethodName: "GetStateList",
 			Handler:    _Executor_GetStateList_Handler,
@@ -755,12 +1809,74 @@ var Executor_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ClearSecretVars",
 			Handler:    _Executor_ClearSecretVars_Handler,
 		},
+		{
+			MethodName: "SetVariables",
+			Handler:    _Executor_SetVariables_Handler,
+		},
+		{
+			MethodName: "ListVariables",
+			Handler:    _Executor_ListVariables_Handler,
+		},
 		{
 			MethodName: "GetMainTf",
 			Handler:    _Executor_GetMainTf_Handler,
 		},
+		{
+			MethodName: "SetRuntime",
+			Handler:    _Executor_SetRuntime_Handler,
+		},
+		{
+			MethodName: "GetRuntime",
+			Handler:    _Executor_GetRuntime_Handler,
+		},
+		{
+			MethodName: "ListRevisions",
+			Handler:    _Executor_ListRevisions_Handler,
+		},
+		{
+			MethodName: "DiffRevisions",
+			Handler:    _Executor_DiffRevisions_Handler,
+		},
+		{
+			MethodName: "RestoreRevision",
+			Handler:    _Executor_RestoreRevision_Handler,
+		},
+		{
+			MethodName: "StartRun",
+			Handler:    _Executor_StartRun_Handler,
+		},
+		{
+			MethodName: "GetRun",
+			Handler:    _Executor_GetRun_Handler,
+		},
+		{
+			MethodName: "GetRunLogs",
+			Handler:    _Executor_GetRunLogs_Handler,
+		},
+		{
+			MethodName: "ListRuns",
+			Handler:    _Executor_ListRuns_Handler,
+		},
+		{
+			MethodName: "CancelRun",
+			Handler:    _Executor_CancelRun_Handler,
+		},
+		{
+			MethodName: "GetSchedulerState",
+			Handler:    _Executor_GetSchedulerState_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadArchive",
+			Handler:       _Executor_UploadArchive_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadArchive",
+			Handler:       _Executor_DownloadArchive_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "StreamLogs",
 			Handler:       _Executor_StreamLogs_Handler,
@@ -53,6 +53,20 @@ func GetCleanupTests(ctx context.Context, svc *executor.ExecutorService, userId,
 					errors = append(errors, "Secret was not deleted")
 				}
 
+				// Check if the values of the variables were deleted
+				secretName = fmt.Sprintf("%s.vars", projectName)
+				if _, err := svc.K8sClient.GetSecret(ctx, userId, secretName); err == nil {
+					errors = append(errors, "variables Secret was not deleted")
+				}
+
+				// Check if the run records were deleted
+				runs, err := svc.K8sClient.ListConfigMaps(ctx, userId, "app=terraform-executor,component=run,project="+projectName)
+				if err != nil {
+					errors = append(errors, fmt.Sprintf("failed to list runs: %v", err))
+				} else if len(runs.Items) > 0 {
+					errors = append(errors, fmt.Sprintf("%d run ConfigMaps were not deleted", len(runs.Items)))
+				}
+
 				if len(errors) > 0 {
 					return fmt.Errorf("deletion failures: %v", errors)
 				}
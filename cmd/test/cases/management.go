@@ -1,12 +1,21 @@
 package cases
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	pb "terraform-executor/api/proto"
 	"terraform-executor/cmd/test/utils"
 	"terraform-executor/internal/executor"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func GetManagementTests(ctx context.Context, svc *executor.ExecutorService, userId, projectName string) []utils.TestCase {
@@ -43,6 +52,48 @@ func GetManagementTests(ctx context.Context, svc *executor.ExecutorService, user
 				return nil
 			},
 		},
+		// Runtime
+		{
+			Name:     "Set runtime",
+			Category: "Management",
+			Fn: func() error {
+				resp, err := svc.SetRuntime(ctx, &pb.SetRuntimeRequest{
+					UserId:  userId,
+					Project: projectName,
+					Engine:  "terraform",
+					Version: "1.9.8",
+				})
+				if err != nil || !resp.Success {
+					return fmt.Errorf("failed to set runtime: %v, %s", err, resp.GetError())
+				}
+				if resp.Image != "hashicorp/terraform:1.9.8" {
+					return fmt.Errorf("unexpected runner image %s", resp.Image)
+				}
+
+				// Verify required_version follows the runtime
+				cm, err := svc.K8sClient.GetConfigMap(ctx, userId, fmt.Sprintf("%s.versions.tf", projectName))
+				if err != nil {
+					return fmt.Errorf("failed to get ConfigMap: %v", err)
+				}
+				if !strings.Contains(cm.Data["versions.tf"], `required_version = "= 1.9.8"`) {
+					return fmt.Errorf("versions.tf does not pin the runtime version")
+				}
+
+				// Versions outside the allowlist are rejected
+				resp, err = svc.SetRuntime(ctx, &pb.SetRuntimeRequest{
+					UserId:  userId,
+					Project: projectName,
+					Version: "0.0.1",
+				})
+				if err != nil {
+					return err
+				}
+				if resp.Success {
+					return fmt.Errorf("expected unknown version to be rejected")
+				}
+				return nil
+			},
+		},
 		// Environment variables
 		{
 			Name:     "Add environment variables",
@@ -90,6 +141,68 @@ func GetManagementTests(ctx context.Context, svc *executor.ExecutorService, user
 				if err != nil || !resp.Success {
 					return fmt.Errorf("failed to add secret var: %v", err)
 				}
+
+				// the values are kept out of variables.tf
+				file, err := svc.GetFile(ctx, &pb.GetFileRequest{UserId: userId, Project: projectName, Path: "variables.tf"})
+				if err != nil || !file.Success {
+					return fmt.Errorf("failed to get variables.tf: %v %s", err, file.GetError())
+				}
+				if strings.Contains(file.Content, "super-secret-password") || !strings.Contains(file.Content, "sensitive = true") {
+					return fmt.Errorf("expected sensitive variables without values, got:\n%s", file.Content)
+				}
+				secret, err := svc.K8sClient.GetSecret(ctx, userId, projectName+".vars")
+				if err != nil {
+					return fmt.Errorf("failed to get variable values: %v", err)
+				}
+				if !strings.Contains(string(secret.Data["executor.auto.tfvars.json"]), `"db_password": "super-secret-password"`) {
+					return fmt.Errorf("expected the value of db_password in Secret %s", secret.Name)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "Set and list typed variables",
+			Category: "Management",
+			Fn: func() error {
+				resp, err := svc.SetVariables(ctx, &pb.SetVariablesRequest{
+					UserId:  userId,
+					Project: projectName,
+					Variables: []*pb.Variable{
+						{Name: "instance_count", Type: "number", ValueJson: "3"},
+						{Name: "tags", Type: "map(string)", Description: "Tags of all resources", ValueJson: `{"team": "infra"}`},
+					},
+				})
+				if err != nil || !resp.Success {
+					return fmt.Errorf("failed to set variables: %v %s", err, resp.GetError())
+				}
+
+				// values not matching the type are rejected
+				bad, err := svc.SetVariables(ctx, &pb.SetVariablesRequest{
+					UserId:    userId,
+					Project:   projectName,
+					Variables: []*pb.Variable{{Name: "instance_count", Type: "number", ValueJson: `"three"`}},
+				})
+				if err != nil || bad.Success {
+					return fmt.Errorf("expected a string to be rejected for a number: %v", err)
+				}
+
+				list, err := svc.ListVariables(ctx, &pb.ListVariablesRequest{UserId: userId, Project: projectName})
+				if err != nil || !list.Success {
+					return fmt.Errorf("failed to list variables: %v %s", err, list.GetError())
+				}
+				found := map[string]*pb.Variable{}
+				for _, v := range list.Variables {
+					found[v.Name] = v
+				}
+				if v := found["instance_count"]; v == nil || v.Type != "number" || v.ValueJson != "3" {
+					return fmt.Errorf("expected instance_count = 3, got %v", v)
+				}
+				if v := found["tags"]; v == nil || v.Description != "Tags of all resources" || v.ValueJson != `{"team":"infra"}` {
+					return fmt.Errorf("expected tags with their description, got %v", v)
+				}
+				if v := found["db_password"]; v == nil || !v.Sensitive || !v.HasValue || v.ValueJson != "" {
+					return fmt.Errorf("expected db_password to be sensitive without its value, got %v", v)
+				}
 				return nil
 			},
 		},
@@ -145,5 +258,307 @@ resource "null_resource" "example" {
 				return nil
 			},
 		},
+		{
+			Name:     "Upsert and remove a block",
+			Category: "Management",
+			Fn: func() error {
+				upsert, err := svc.UpsertBlock(ctx, &pb.UpsertBlockRequest{
+					UserId:  userId,
+					Project: projectName,
+					Code:    "resource \"null_resource\" \"temporary\" {}",
+				})
+				if err != nil || !upsert.Success {
+					return fmt.Errorf("failed to upsert block: %v %s", err, upsert.GetError())
+				}
+				if !upsert.Created || upsert.Block.Address != "null_resource.temporary" {
+					return fmt.Errorf("unexpected upserted block: %v", upsert.Block)
+				}
+
+				list, err := svc.ListBlocks(ctx, &pb.ListBlocksRequest{UserId: userId, Project: projectName})
+				if err != nil || !list.Success {
+					return fmt.Errorf("failed to list blocks: %v %s", err, list.GetError())
+				}
+				if len(list.Blocks) != 2 {
+					return fmt.Errorf("expected 2 blocks, got %d", len(list.Blocks))
+				}
+
+				remove, err := svc.RemoveBlock(ctx, &pb.RemoveBlockRequest{
+					UserId:  userId,
+					Project: projectName,
+					Address: "null_resource.temporary",
+				})
+				if err != nil || !remove.Success {
+					return fmt.Errorf("failed to remove block: %v %s", err, remove.GetError())
+				}
+
+				// the appended block is still there
+				get, err := svc.GetBlock(ctx, &pb.GetBlockRequest{
+					UserId:  userId,
+					Project: projectName,
+					Address: "null_resource.example",
+				})
+				if err != nil || !get.Success {
+					return fmt.Errorf("failed to get block: %v %s", err, get.GetError())
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "Diff and restore revisions",
+			Category: "Management",
+			Fn: func() error {
+				list, err := svc.ListRevisions(ctx, &pb.ListRevisionsRequest{UserId: userId, Project: projectName})
+				if err != nil || !list.Success {
+					return fmt.Errorf("failed to list revisions: %v %s", err, list.GetError())
+				}
+				if len(list.Revisions) < 2 || list.Revisions[0].Action != "RemoveBlock" {
+					return fmt.Errorf("unexpected revisions: %v", list.Revisions)
+				}
+
+				// the latest revision removed the temporary block
+				diff, err := svc.DiffRevisions(ctx, &pb.DiffRevisionsRequest{UserId: userId, Project: projectName})
+				if err != nil || !diff.Success {
+					return fmt.Errorf("failed to diff revisions: %v %s", err, diff.GetError())
+				}
+				if len(diff.Diffs) != 1 || !strings.Contains(diff.Diffs[0].Diff, "-resource \"null_resource\" \"temporary\" {}") {
+					return fmt.Errorf("unexpected diff: %v", diff.Diffs)
+				}
+
+				// restoring the revision before it brings the block back, then restore the latest one
+				latest := list.Revisions[0].Number
+				for _, number := range []int64{latest - 1, latest} {
+					restore, err := svc.RestoreRevision(ctx, &pb.RestoreRevisionRequest{
+						UserId:   userId,
+						Project:  projectName,
+						Revision: number,
+					})
+					if err != nil || !restore.Success {
+						return fmt.Errorf("failed to restore revision %d: %v %s", number, err, restore.GetError())
+					}
+					if restore.Revision.RestoredFrom != number {
+						return fmt.Errorf("unexpected restored revision: %v", restore.Revision)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "Reject a stale expected revision",
+			Category: "Management",
+			Fn: func() error {
+				get, err := svc.GetMainTf(ctx, &pb.GetMainTfRequest{UserId: userId, Project: projectName})
+				if err != nil || !get.Success {
+					return fmt.Errorf("failed to get main.tf: %v %s", err, get.GetError())
+				}
+
+				_, err = svc.AppendCode(ctx, &pb.AppendCodeRequest{
+					UserId:           userId,
+					Project:          projectName,
+					Code:             "resource \"null_resource\" \"stale\" {}",
+					ExpectedRevision: get.Revision - 1,
+				})
+				if status.Code(err) != codes.Aborted {
+					return fmt.Errorf("expected Aborted, got %v", err)
+				}
+
+				// the latest revision is accepted, restoring it leaves the project as it is
+				restore, err := svc.RestoreRevision(ctx, &pb.RestoreRevisionRequest{
+					UserId:           userId,
+					Project:          projectName,
+					Revision:         get.Revision,
+					ExpectedRevision: get.Revision,
+				})
+				if err != nil || !restore.Success {
+					return fmt.Errorf("failed to restore revision: %v %s", err, restore.GetError())
+				}
+				if restore.Revision.Number != get.Revision {
+					return fmt.Errorf("expected revision %d, got %d", get.Revision, restore.Revision.Number)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "Put, list and delete files",
+			Category: "Management",
+			Fn: func() error {
+				put, err := svc.PutFile(ctx, &pb.PutFileRequest{
+					UserId:  userId,
+					Project: projectName,
+					Path:    "modules/example/outputs.tf",
+					Content: "output \"id\" {\n  value = \"example\"\n}\n",
+				})
+				if err != nil || !put.Success || !put.Created {
+					return fmt.Errorf("failed to put file: %v %s", err, put.GetError())
+				}
+
+				// traversal is rejected
+				bad, err := svc.PutFile(ctx, &pb.PutFileRequest{
+					UserId:  userId,
+					Project: projectName,
+					Path:    "../outside.tf",
+					Content: "",
+				})
+				if err != nil || bad.Success {
+					return fmt.Errorf("expected path traversal to be rejected: %v", err)
+				}
+
+				list, err := svc.ListFiles(ctx, &pb.ListFilesRequest{UserId: userId, Project: projectName})
+				if err != nil || !list.Success {
+					return fmt.Errorf("failed to list files: %v %s", err, list.GetError())
+				}
+				if list.Files[len(list.Files)-1].Path != "modules/example/outputs.tf" {
+					return fmt.Errorf("unexpected files: %v", list.Files)
+				}
+
+				del, err := svc.DeleteFile(ctx, &pb.DeleteFileRequest{
+					UserId:  userId,
+					Project: projectName,
+					Path:    "modules/example/outputs.tf",
+				})
+				if err != nil || !del.Success {
+					return fmt.Errorf("failed to delete file: %v %s", err, del.GetError())
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "Download and upload an archive",
+			Category: "Management",
+			Fn: func() error {
+				download := &utils.ServerStream[pb.DownloadArchiveResponse]{Ctx: ctx}
+				err := svc.DownloadArchive(&pb.DownloadArchiveRequest{UserId: userId, Project: projectName}, download)
+				if err != nil || len(download.Sent) == 0 || !download.Sent[0].Success {
+					return fmt.Errorf("failed to download archive: %v %v", err, download.Sent)
+				}
+				var archive []byte
+				for _, msg := range download.Sent {
+					archive = append(archive, msg.Chunk...)
+				}
+
+				// uploading the files back in two chunks leaves the project as it is
+				upload := &utils.ClientStream[pb.UploadArchiveRequest, pb.UploadArchiveResponse]{
+					Ctx: ctx,
+					Requests: []*pb.UploadArchiveRequest{
+						{UserId: userId, Project: projectName, Chunk: archive[:len(archive)/2]},
+						{Chunk: archive[len(archive)/2:]},
+					},
+				}
+				if err := svc.UploadArchive(upload); err != nil || !upload.Response.Success {
+					return fmt.Errorf("failed to upload archive: %v %s", err, upload.Response.GetError())
+				}
+				if upload.Response.Revision != download.Sent[0].Revision {
+					return fmt.Errorf("expected revision %d, got %d", download.Sent[0].Revision, upload.Response.Revision)
+				}
+
+				// entries outside the working directory reject the archive
+				var buf bytes.Buffer
+				gz := gzip.NewWriter(&buf)
+				tw := tar.NewWriter(gz)
+				if err := tw.WriteHeader(&tar.Header{Name: "../outside.tf", Typeflag: tar.TypeReg, Mode: 0o644}); err != nil {
+					return err
+				}
+				tw.Close()
+				gz.Close()
+				bad := &utils.ClientStream[pb.UploadArchiveRequest, pb.UploadArchiveResponse]{
+					Ctx:      ctx,
+					Requests: []*pb.UploadArchiveRequest{{UserId: userId, Project: projectName, Chunk: buf.Bytes()}},
+				}
+				if err := svc.UploadArchive(bad); err != nil || bad.Response.Success {
+					return fmt.Errorf("expected path traversal to be rejected: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "Bind and sync from git",
+			Category: "Management",
+			Fn: func() error {
+				download := &utils.ServerStream[pb.DownloadArchiveResponse]{Ctx: ctx}
+				err := svc.DownloadArchive(&pb.DownloadArchiveRequest{UserId: userId, Project: projectName}, download)
+				if err != nil || len(download.Sent) == 0 || !download.Sent[0].Success {
+					return fmt.Errorf("failed to download archive: %v %v", err, download.Sent)
+				}
+				var archive []byte
+				for _, msg := range download.Sent {
+					archive = append(archive, msg.Chunk...)
+				}
+
+				// a local repository holding the current files in a subdirectory
+				dir, err := os.MkdirTemp("", "git-sync-test-")
+				if err != nil {
+					return err
+				}
+				defer os.RemoveAll(dir)
+				if err := os.WriteFile(filepath.Join(dir, "files.tar.gz"), archive, 0o644); err != nil {
+					return err
+				}
+				script := `set -e
+mkdir -p src/infra && tar -xzf files.tar.gz -C src/infra && cd src
+git init -q && git add . && git -c user.name=test -c user.email=test@example.com commit -qm files
+cd .. && git clone -q --bare src repo.git && git -C src rev-parse HEAD`
+				cmd := exec.Command("sh", "-c", script)
+				cmd.Dir = dir
+				out, err := cmd.Output()
+				if err != nil {
+					return fmt.Errorf("failed to create repository: %v", err)
+				}
+				commit := strings.TrimSpace(string(out))
+
+				// file:// URLs expose the filesystem of the executor, only tests allow them
+				os.Setenv("GIT_ALLOW_FILE_URLS", "false")
+				bind, err := svc.BindGit(ctx, &pb.BindGitRequest{
+					UserId:  userId,
+					Project: projectName,
+					Url:     "file://" + filepath.Join(dir, "repo.git"),
+				})
+				if err != nil || bind.Success {
+					return fmt.Errorf("expected file:// URLs to be rejected by default, got %v %v", err, bind)
+				}
+				os.Setenv("GIT_ALLOW_FILE_URLS", "true")
+				defer os.Unsetenv("GIT_ALLOW_FILE_URLS")
+
+				bind, err = svc.BindGit(ctx, &pb.BindGitRequest{
+					UserId:       userId,
+					Project:      projectName,
+					Url:          "file://" + filepath.Join(dir, "repo.git"),
+					Subdirectory: "infra",
+				})
+				if err != nil || !bind.Success {
+					return fmt.Errorf("failed to bind git: %v %s", err, bind.GetError())
+				}
+				defer svc.UnbindGit(ctx, &pb.UnbindGitRequest{UserId: userId, Project: projectName})
+
+				// the files of a bound project are only changed by syncing
+				appendResp, err := svc.AppendCode(ctx, &pb.AppendCodeRequest{UserId: userId, Project: projectName, Code: "# not synced"})
+				if err != nil || appendResp.Success {
+					return fmt.Errorf("expected AppendCode to be rejected for a bound project: %v", err)
+				}
+
+				sync, err := svc.SyncFromGit(ctx, &pb.SyncFromGitRequest{UserId: userId, Project: projectName})
+				if err != nil || !sync.Success {
+					return fmt.Errorf("failed to sync from git: %v %s", err, sync.GetError())
+				}
+				if sync.Commit != commit {
+					return fmt.Errorf("expected commit %s, got %s", commit, sync.Commit)
+				}
+				if sync.Revision != download.Sent[0].Revision {
+					return fmt.Errorf("expected revision %d for the same files, got %d", download.Sent[0].Revision, sync.Revision)
+				}
+				get, err := svc.GetGitBinding(ctx, &pb.GetGitBindingRequest{UserId: userId, Project: projectName})
+				if err != nil || !get.Success || get.Binding.GetCommit() != commit {
+					return fmt.Errorf("expected binding at commit %s: %v %v", commit, err, get.GetBinding())
+				}
+
+				unbind, err := svc.UnbindGit(ctx, &pb.UnbindGitRequest{UserId: userId, Project: projectName})
+				if err != nil || !unbind.Success {
+					return fmt.Errorf("failed to unbind git: %v %s", err, unbind.GetError())
+				}
+				get, err = svc.GetGitBinding(ctx, &pb.GetGitBindingRequest{UserId: userId, Project: projectName})
+				if err != nil || !get.Success || get.Binding != nil {
+					return fmt.Errorf("expected no binding after unbinding: %v %v", err, get.GetBinding())
+				}
+				return nil
+			},
+		},
 	}
 }
@@ -4,13 +4,41 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	pb "terraform-executor/api/proto"
 	"terraform-executor/cmd/test/utils"
 	"terraform-executor/internal/executor"
+	"time"
 )
 
 func GetTerraformTests(ctx context.Context, svc *executor.ExecutorService, userId, projectName string) []utils.TestCase {
+	// ID of the saved plan, shared between the plan and apply tests
+	var planId string
+
 	return []utils.TestCase{
+		{
+			Name:     "Validate configuration",
+			Category: "Terraform",
+			Fn: func() error {
+				resp, err := svc.Validate(ctx, &pb.ValidateRequest{
+					UserId:  userId,
+					Project: projectName,
+				})
+				if err != nil {
+					return fmt.Errorf("RPC error: %v", err)
+				}
+				if !resp.Success {
+					return fmt.Errorf("❌ validate failed: %s", resp.Error)
+				}
+				for _, d := range resp.Errors {
+					log.Printf("❌ %s:%d: %s", d.Filename, d.StartLine, d.Summary)
+				}
+				if !resp.Valid {
+					return fmt.Errorf("configuration is invalid: %d errors", len(resp.Errors))
+				}
+				return nil
+			},
+		},
 		{
 			Name:     "Plan infrastructure",
 			Category: "Terraform",
@@ -40,16 +68,96 @@ func GetTerraformTests(ctx context.Context, svc *executor.ExecutorService, userI
 					return fmt.Errorf("plan succeeded but output is empty")
 				}
 
+				// Verify the plan was saved
+				if resp.PlanId == "" {
+					return fmt.Errorf("plan succeeded but plan id is empty")
+				}
+				planId = resp.PlanId
+
+				// Verify the structured result, the test configuration creates resources
+				if !resp.HasChanges || resp.ToAdd == 0 || len(resp.ResourceChanges) == 0 {
+					return fmt.Errorf("plan succeeded but reports no changes")
+				}
+
 				return nil
 			},
 		},
 		{
-			Name:     "Apply infrastructure",
+			Name:     "Plan infrastructure asynchronously",
 			Category: "Terraform",
 			Fn: func() error {
-				resp, err := svc.Apply(ctx, &pb.ApplyRequest{
+				resp, err := svc.StartRun(ctx, &pb.StartRunRequest{
 					UserId:  userId,
 					Project: projectName,
+					Type:    pb.Run_PLAN,
+				})
+				if err != nil {
+					return fmt.Errorf("RPC error: %v", err)
+				}
+				if !resp.Success {
+					return fmt.Errorf("❌ start run failed: %s", resp.Error)
+				}
+
+				// Poll the run until it finished
+				for {
+					runResp, err := svc.GetRun(ctx, &pb.GetRunRequest{
+						UserId:  userId,
+						Project: projectName,
+						RunId:   resp.RunId,
+					})
+					if err != nil {
+						return fmt.Errorf("RPC error: %v", err)
+					}
+					if !runResp.Success {
+						return fmt.Errorf("❌ get run failed: %s", runResp.Error)
+					}
+					switch runResp.Run.Phase {
+					case pb.Run_SUCCEEDED:
+						log.Printf("\n🔍 Terraform Run Output:\n%s\n", runResp.Run.Output)
+					case pb.Run_FAILED, pb.Run_CANCELLED:
+						return fmt.Errorf("❌ run %s: %s", runResp.Run.Phase, runResp.Run.Error)
+					default:
+						time.Sleep(2 * time.Second)
+						continue
+					}
+					break
+				}
+
+				// The secret env vars are referenced, their values aren't in the Job
+				job, err := svc.K8sClient.GetJob(ctx, userId, fmt.Sprintf("terraform-plan-%s", resp.RunId))
+				if err != nil {
+					return fmt.Errorf("failed to get job: %v", err)
+				}
+				for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+					if env.Name == "AWS_ACCESS_KEY_ID" && (env.Value != "" || env.ValueFrom == nil || env.ValueFrom.SecretKeyRef == nil) {
+						return fmt.Errorf("expected AWS_ACCESS_KEY_ID to reference its Secret, got %+v", env)
+					}
+				}
+
+				// Verify the run is listed
+				listResp, err := svc.ListRuns(ctx, &pb.ListRunsRequest{
+					UserId:  userId,
+					Project: projectName,
+				})
+				if err != nil {
+					return fmt.Errorf("RPC error: %v", err)
+				}
+				for _, r := range listResp.Runs {
+					if r.RunId == resp.RunId {
+						return nil
+					}
+				}
+				return fmt.Errorf("run %s is not listed", resp.RunId)
+			},
+		},
+		{
+			Name:     "Apply infrastructure",
+			Category: "Terraform",
+			Fn: func() error {
+				resp, err := svc.Apply(ctx, &pb.ApplyRequest{
+					UserId:   userId,
+					Project:  projectName,
+					PlanFile: planId,
 				})
 
 				// First check if the RPC call itself failed
@@ -75,6 +183,47 @@ func GetTerraformTests(ctx context.Context, svc *executor.ExecutorService, userI
 				return nil
 			},
 		},
+		{
+			Name:     "Get archived run logs",
+			Category: "Terraform",
+			Fn: func() error {
+				listResp, err := svc.ListRuns(ctx, &pb.ListRunsRequest{
+					UserId:  userId,
+					Project: projectName,
+				})
+				if err != nil || !listResp.Success {
+					return fmt.Errorf("failed to list runs: %v, %s", err, listResp.GetError())
+				}
+				var applyRun *pb.Run
+				for _, r := range listResp.Runs {
+					if r.Type == pb.Run_APPLY {
+						applyRun = r
+						break
+					}
+				}
+				if applyRun == nil {
+					return fmt.Errorf("apply run is not listed")
+				}
+
+				resp, err := svc.GetRunLogs(ctx, &pb.GetRunLogsRequest{
+					UserId:  userId,
+					Project: projectName,
+					RunId:   applyRun.RunId,
+				})
+				if err != nil || !resp.Success {
+					return fmt.Errorf("failed to get run logs: %v, %s", err, resp.GetError())
+				}
+				if len(resp.Content) == 0 {
+					return fmt.Errorf("runner log of run %s is empty", applyRun.RunId)
+				}
+				for _, file := range []string{"tfplan", "state-post.tfstate"} {
+					if !slices.Contains(resp.Files, file) {
+						return fmt.Errorf("%s not archived for run %s", file, applyRun.RunId)
+					}
+				}
+				return nil
+			},
+		},
 		{
 			Name:     "Get State List",
 			Category: "Terraform",
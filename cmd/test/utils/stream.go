@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+)
+
+// ServerStream collects the messages of a server-streaming RPC called on the service directly
+type ServerStream[Res any] struct {
+	grpc.ServerStream
+	Ctx  context.Context
+	Sent []*Res
+}
+
+func (s *ServerStream[Res]) Context() context.Context {
+	return s.Ctx
+}
+
+func (s *ServerStream[Res]) Send(m *Res) error {
+	s.Sent = append(s.Sent, m)
+	return nil
+}
+
+// ClientStream feeds the messages of a client-streaming RPC called on the service directly
+type ClientStream[Req, Res any] struct {
+	grpc.ServerStream
+	Ctx      context.Context
+	Requests []*Req
+	Response *Res
+}
+
+func (s *ClientStream[Req, Res]) Context() context.Context {
+	return s.Ctx
+}
+
+func (s *ClientStream[Req, Res]) Recv() (*Req, error) {
+	if len(s.Requests) == 0 {
+		return nil, io.EOF
+	}
+	m := s.Requests[0]
+	s.Requests = s.Requests[1:]
+	return m, nil
+}
+
+func (s *ClientStream[Req, Res]) SendAndClose(m *Res) error {
+	s.Response = m
+	return nil
+}
@@ -0,0 +1,220 @@
+package awsclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrObjectNotFound is returned when the requested S3 object does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrPreconditionFailed is returned when a conditional write finds the object changed
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Region returns the AWS region the client is configured for
+func (c *AWSClient) Region() string {
+	return c.cfg.Region
+}
+
+// PutObject uploads content to the given bucket and key
+// Required IAM permissions: s3:PutObject
+func (c *AWSClient) PutObject(ctx context.Context, bucket, key string, content []byte) error {
+	_, err := c.S3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetObject downloads the content of the given bucket and key
+// Required IAM permissions: s3:GetObject
+func (c *AWSClient) GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	result, err := c.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%s: %w", key, ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return content, nil
+}
+
+// ObjectExists checks if the given key exists in the bucket
+// Required IAM permissions: s3:GetObject
+func (c *AWSClient) ObjectExists(ctx context.Context, bucket, key string) (bool, error) {
+	_, err := c.S3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check object %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// ObjectSize returns the size of the given key, it fails with ErrObjectNotFound if the key doesn't exist
+// Required IAM permissions: s3:GetObject
+func (c *AWSClient) ObjectSize(ctx context.Context, bucket, key string) (int64, error) {
+	result, err := c.S3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return 0, fmt.Errorf("%s: %w", key, ErrObjectNotFound)
+		}
+		return 0, fmt.Errorf("failed to check object %s: %w", key, err)
+	}
+	return aws.ToInt64(result.ContentLength), nil
+}
+
+// GetObjectRange downloads length bytes of the given key starting at offset
+// Required IAM permissions: s3:GetObject
+func (c *AWSClient) GetObjectRange(ctx context.Context, bucket, key string, offset, length int64) ([]byte, error) {
+	result, err := c.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("%s: %w", key, ErrObjectNotFound)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return content, nil
+}
+
+// CopyObject copies an object within the bucket, it fails with ErrObjectNotFound if the source doesn't exist
+// Required IAM permissions: s3:GetObject, s3:PutObject
+func (c *AWSClient) CopyObject(ctx context.Context, bucket, srcKey, dstKey string) error {
+	_, err := c.S3Client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		CopySource: aws.String(bucket + "/" + srcKey),
+		Key:        aws.String(dstKey),
+	})
+	if err != nil {
+		// CopyObject reports a missing source as a generic API error
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
+			return fmt.Errorf("%s: %w", srcKey, ErrObjectNotFound)
+		}
+		return fmt.Errorf("failed to copy object %s to %s: %w", srcKey, dstKey, err)
+	}
+	return nil
+}
+
+// ListObjects returns the keys under the given prefix
+// Required IAM permissions: s3:ListBucket
+func (c *AWSClient) ListObjects(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(c.S3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+// GetObjectETag downloads the content of the given key with its ETag, it fails with ErrObjectNotFound if the key doesn't exist
+// Required IAM permissions: s3:GetObject
+func (c *AWSClient) GetObjectETag(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	result, err := c.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", fmt.Errorf("%s: %w", key, ErrObjectNotFound)
+		}
+		return nil, "", fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer result.Body.Close()
+
+	content, err := io.ReadAll(result.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return content, aws.ToString(result.ETag), nil
+}
+
+// PutObjectIf uploads content if the key is still at the given ETag, an empty ETag requires the key to not exist.
+// It fails with ErrPreconditionFailed otherwise.
+// Required IAM permissions: s3:PutObject
+func (c *AWSClient) PutObjectIf(ctx context.Context, bucket, key string, content []byte, etag string) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	if _, err := c.S3Client.PutObject(ctx, input); err != nil {
+		// a concurrent conditional write of the same key is reported as a conflict
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+			return fmt.Errorf("%s: %w", key, ErrPreconditionFailed)
+		}
+		return fmt.Errorf("failed to put object %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteObject deletes the given key, a missing key is no error
+// Required IAM permissions: s3:DeleteObject
+func (c *AWSClient) DeleteObject(ctx context.Context, bucket, key string) error {
+	_, err := c.S3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object %s: %w", key, err)
+	}
+	return nil
+}
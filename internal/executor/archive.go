@@ -0,0 +1,343 @@
+package executor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+const (
+	archiveTarGz = "tar.gz"
+	archiveZip   = "zip"
+
+	// maxArchiveEntries bounds the number of files of an archive
+	maxArchiveEntries = 1000
+	// archiveChunkSize is the size of the chunks of a downloaded archive
+	archiveChunkSize = 64 << 10
+)
+
+// detectArchiveFormat returns the format of an archive by its magic number, empty if it is unknown
+func detectArchiveFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return archiveTarGz
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")), bytes.HasPrefix(data, []byte("PK\x05\x06")):
+		return archiveZip
+	}
+	return ""
+}
+
+// archiveEntries collects the files of an archive within the limits of the project files
+type archiveEntries struct {
+	limits fileLimits
+	files  map[string]string
+	total  int64
+}
+
+// entryPath returns the path of an entry, archives of a directory often prefix it with ./
+func entryPath(name string) string {
+	return strings.TrimPrefix(name, "./")
+}
+
+// dir checks the path of a directory entry, directories aren't stored
+func (a *archiveEntries) dir(name string) error {
+	p := strings.TrimSuffix(entryPath(name), "/")
+	if p == "" || p == "." {
+		return nil
+	}
+	return validateFilePath(p)
+}
+
+// add reads a regular file, it is read up to the limits only
+func (a *archiveEntries) add(name string, r io.Reader) error {
+	p := entryPath(name)
+	if err := validateFilePath(p); err != nil {
+		return err
+	}
+	if _, ok := a.files[p]; ok {
+		return fmt.Errorf("%s is contained twice", p)
+	}
+	if len(a.files) >= maxArchiveEntries {
+		return fmt.Errorf("archives may contain at most %d files", maxArchiveEntries)
+	}
+	content, err := io.ReadAll(io.LimitReader(r, a.limits.File+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", p, err)
+	}
+	if int64(len(content)) > a.limits.File {
+		return fmt.Errorf("%s exceeds the maximum file size of %d bytes", p, a.limits.File)
+	}
+	if a.total += int64(len(content)); a.total > a.limits.Total {
+		return fmt.Errorf("the files exceed the maximum project size of %d bytes", a.limits.Total)
+	}
+	if !utf8.Valid(content) {
+		return fmt.Errorf("%s is not valid UTF-8", p)
+	}
+	a.files[p] = string(content)
+	return nil
+}
+
+// extractArchive returns the files of an archive. Only regular files and directories inside
+// the working directory are accepted, links and other entries are rejected.
+func extractArchive(format string, data []byte, limits fileLimits) (map[string]string, error) {
+	entries := &archiveEntries{limits: limits, files: make(map[string]string)}
+	switch format {
+	case archiveTarGz:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar.gz archive: %v", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			// insecure names are rejected by the path validation below
+			if err != nil && !errors.Is(err, tar.ErrInsecurePath) {
+				return nil, fmt.Errorf("invalid tar.gz archive: %v", err)
+			}
+			switch hdr.Typeflag {
+			case tar.TypeReg:
+				err = entries.add(hdr.Name, tr)
+			case tar.TypeDir:
+				err = entries.dir(hdr.Name)
+			case tar.TypeXGlobalHeader:
+				err = nil
+			case tar.TypeSymlink, tar.TypeLink:
+				err = fmt.Errorf("%s is a link, links are not allowed", hdr.Name)
+			default:
+				err = fmt.Errorf("%s is not a regular file", hdr.Name)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	case archiveZip:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+			return nil, fmt.Errorf("invalid zip archive: %v", err)
+		}
+		for _, f := range zr.File {
+			mode := f.Mode()
+			switch {
+			case mode&fs.ModeSymlink != 0:
+				return nil, fmt.Errorf("%s is a link, links are not allowed", f.Name)
+			case mode.IsDir():
+				if err := entries.dir(f.Name); err != nil {
+					return nil, err
+				}
+				continue
+			case !mode.IsRegular():
+				return nil, fmt.Errorf("%s is not a regular file", f.Name)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("invalid zip archive: %v", err)
+			}
+			err = entries.add(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q, must be %s or %s", format, archiveTarGz, archiveZip)
+	}
+	return entries.files, nil
+}
+
+// writeArchive returns an archive of a set of files
+func writeArchive(format string, files map[string]string, modTime time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case archiveTarGz:
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for _, p := range sortedPaths(files) {
+			if err := tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     p,
+				Mode:     0o644,
+				Size:     int64(len(files[p])),
+				ModTime:  modTime,
+			}); err != nil {
+				return nil, err
+			}
+			if _, err := io.WriteString(tw, files[p]); err != nil {
+				return nil, err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	case archiveZip:
+		zw := zip.NewWriter(&buf)
+		for _, p := range sortedPaths(files) {
+			hdr := &zip.FileHeader{Name: p, Method: zip.Deflate, Modified: modTime}
+			hdr.SetMode(0o644)
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := io.WriteString(w, files[p]); err != nil {
+				return nil, err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q, must be %s or %s", format, archiveTarGz, archiveZip)
+	}
+	return buf.Bytes(), nil
+}
+
+// UploadArchive replaces all files of the project with the files of an archive. All entries are
+// validated before any file is written, the new files are recorded as one revision.
+func (s *ExecutorService) UploadArchive(stream pb.Executor_UploadArchiveServer) error {
+	// the compressed archive is bounded too, tar headers and padding may exceed the size of the files
+	maxSize := 2*s.fileLimits.Total + 1<<20
+	var first *pb.UploadArchiveRequest
+	var data []byte
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first == nil {
+			first = req
+		}
+		if int64(len(data)+len(req.Chunk)) > maxSize {
+			return stream.SendAndClose(&pb.UploadArchiveResponse{
+				Success: false,
+				Error:   fmt.Sprintf("the archive has more than %d bytes", maxSize),
+			})
+		}
+		data = append(data, req.Chunk...)
+	}
+	if first == nil {
+		return stream.SendAndClose(&pb.UploadArchiveResponse{Success: false, Error: "no archive was uploaded"})
+	}
+	resp, err := s.uploadArchive(stream.Context(), first, data)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+// uploadArchive replaces the project files with an uploaded archive, only an aborted mutation returns an error
+func (s *ExecutorService) uploadArchive(ctx context.Context, req *pb.UploadArchiveRequest, data []byte) (*pb.UploadArchiveResponse, error) {
+	format := req.Format
+	if format == "" {
+		if format = detectArchiveFormat(data); format == "" {
+			return &pb.UploadArchiveResponse{Success: false, Error: "unknown archive format, must be tar.gz or zip"}, nil
+		}
+	}
+	files, err := extractArchive(format, data, s.fileLimits)
+	if err != nil {
+		return &pb.UploadArchiveResponse{Success: false, Error: fmt.Sprintf("invalid archive: %v", err)}, nil
+	}
+	var diags hcl.Diagnostics
+	for _, p := range sortedPaths(files) {
+		diags = append(diags, checkFile(p, files[p])...)
+	}
+	if diags.HasErrors() {
+		return &pb.UploadArchiveResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid archive: %v", diags.Error()),
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
+	}
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.UploadArchiveResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "UploadArchive"}, func() error {
+		return s.replaceFiles(ctx, req.UserId, req.Project, files)
+	})
+	if err != nil {
+		return &pb.UploadArchiveResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	resp := &pb.UploadArchiveResponse{Success: true, Revision: revision}
+	resp.Files, resp.TotalSize = s.listedFiles(req.Project, files)
+	return resp, nil
+}
+
+// listedFiles lists a set of files replacing the project files with their total size
+func (s *ExecutorService) listedFiles(project string, files map[string]string) ([]*pb.ProjectFile, int64) {
+	var list []*pb.ProjectFile
+	var total int64
+	for _, p := range sortedPaths(files) {
+		size := int64(len(files[p]))
+		list = append(list, &pb.ProjectFile{
+			Path:      p,
+			Size:      size,
+			ConfigMap: s.store.ConfigMap(project, p),
+		})
+		total += size
+	}
+	return list, total
+}
+
+// DownloadArchive streams the files of the project, or of one of its revisions, as an archive.
+func (s *ExecutorService) DownloadArchive(req *pb.DownloadArchiveRequest, stream pb.Executor_DownloadArchiveServer) error {
+	ctx := stream.Context()
+	format := req.Format
+	if format == "" {
+		format = archiveTarGz
+	}
+
+	var files map[string]string
+	revision, modTime := req.Revision, time.Now().UTC()
+	if revision != 0 {
+		rev, err := s.getRevision(ctx, req.UserId, req.Project, revision)
+		if err != nil {
+			return stream.Send(&pb.DownloadArchiveResponse{Success: false, Error: err.Error()})
+		}
+		files, modTime = rev.Files, rev.CreatedAt
+	} else {
+		var err error
+		if files, err = s.store.List(ctx, req.UserId, req.Project); err != nil {
+			return stream.Send(&pb.DownloadArchiveResponse{Success: false, Error: err.Error()})
+		}
+		if revision, err = s.latestRevision(ctx, req.UserId, req.Project); err != nil {
+			return stream.Send(&pb.DownloadArchiveResponse{Success: false, Error: err.Error()})
+		}
+	}
+
+	data, err := writeArchive(format, files, modTime)
+	if err != nil {
+		return stream.Send(&pb.DownloadArchiveResponse{Success: false, Error: fmt.Sprintf("failed to write archive: %v", err)})
+	}
+	resp := &pb.DownloadArchiveResponse{Success: true, Format: format, Revision: revision}
+	for {
+		n := min(len(data), archiveChunkSize)
+		resp.Chunk = data[:n]
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+		if data = data[n:]; len(data) == 0 {
+			return nil
+		}
+		resp = &pb.DownloadArchiveResponse{Success: true}
+	}
+}
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	pb "terraform-executor/api/proto"
+
+	"terraform-executor/internal/awsclient"
+)
+
+const (
+	// defaultRunLogsLimit is the page size of GetRunLogs unless the request sets one
+	defaultRunLogsLimit = 64 * 1024
+	// maxRunLogsLimit bounds the page size of GetRunLogs to stay below the gRPC message size limit
+	maxRunLogsLimit = 1024 * 1024
+)
+
+// runPrefix returns the S3 prefix where the logs and artifacts of a run are stored
+func runPrefix(userId, project, runId string) string {
+	return fmt.Sprintf("%s/%s/runs/%s", userId, project, runId)
+}
+
+// stateKey returns the key of the project state, matching the backend rendered by AddProviders
+func stateKey(userId, project string) string {
+	return fmt.Sprintf("%s/%s/terraform.tfstate", userId, project)
+}
+
+// snapshotState copies the current state of the project next to the artifacts of the run
+func (s *ExecutorService) snapshotState(ctx context.Context, r *run, name string) {
+	err := s.AWSClient.CopyObject(ctx, s.Bucket, stateKey(r.UserID, r.Project), runPrefix(r.UserID, r.Project, r.ID)+"/"+name)
+	if err != nil && !errors.Is(err, awsclient.ErrObjectNotFound) {
+		fmt.Printf("⚠️ Failed to snapshot state of run %s: %v\n", r.ID, err)
+	}
+}
+
+// archiveRunLogs uploads the full logs of the containers of the run's pod, if it still exists
+func (s *ExecutorService) archiveRunLogs(ctx context.Context, r *run) {
+	if r.JobName == "" {
+		return
+	}
+	pod, err := s.K8sClient.GetJobPod(ctx, r.UserID, r.JobName)
+	if err != nil {
+		return
+	}
+	containers := []string{}
+	for _, c := range pod.Spec.InitContainers {
+		containers = append(containers, c.Name)
+	}
+	containers = append(containers, "runner")
+	for _, container := range containers {
+		logs, err := s.K8sClient.GetPodLogs(ctx, r.UserID, pod.Name, container)
+		if err != nil {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s.log", runPrefix(r.UserID, r.Project, r.ID), container)
+		if err := s.AWSClient.PutObject(ctx, s.Bucket, key, []byte(logs)); err != nil {
+			fmt.Printf("⚠️ Failed to archive %s logs of run %s: %v\n", container, r.ID, err)
+		}
+	}
+}
+
+// archiveRun stores the logs, the plan and the state after a mutating run in the bucket.
+// Runner Jobs are deleted shortly after they finished, so it runs as soon as the Job completed.
+func (s *ExecutorService) archiveRun(ctx context.Context, r *run) {
+	s.archiveRunLogs(ctx, r)
+
+	if r.PlanID != "" {
+		for _, file := range []string{"tfplan", "plan.json"} {
+			src := planPrefix(r.UserID, r.Project, r.PlanID) + "/" + file
+			err := s.AWSClient.CopyObject(ctx, s.Bucket, src, runPrefix(r.UserID, r.Project, r.ID)+"/"+file)
+			if err != nil && !errors.Is(err, awsclient.ErrObjectNotFound) {
+				fmt.Printf("⚠️ Failed to archive %s of run %s: %v\n", file, r.ID, err)
+			}
+		}
+	}
+	if r.mutating() {
+		s.snapshotState(ctx, r, "state-post.tfstate")
+	}
+}
+
+// GetRunLogs returns a page of the archived logs or artifacts of a run.
+func (s *ExecutorService) GetRunLogs(ctx context.Context, req *pb.GetRunLogsRequest) (*pb.GetRunLogsResponse, error) {
+	if _, err := s.getRun(ctx, req.UserId, req.Project, req.RunId); err != nil {
+		return &pb.GetRunLogsResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	prefix := runPrefix(req.UserId, req.Project, req.RunId) + "/"
+	keys, err := s.AWSClient.ListObjects(ctx, s.Bucket, prefix)
+	if err != nil {
+		return &pb.GetRunLogsResponse{Success: false, Error: err.Error()}, nil
+	}
+	files := make([]string, 0, len(keys))
+	for _, key := range keys {
+		files = append(files, strings.TrimPrefix(key, prefix))
+	}
+
+	file := req.File
+	if file == "" {
+		file = "runner.log"
+	}
+	if strings.Contains(file, "/") {
+		return &pb.GetRunLogsResponse{Success: false, Error: fmt.Sprintf("invalid file %q", file), Files: files}, nil
+	}
+	if req.Offset < 0 || req.Limit < 0 {
+		return &pb.GetRunLogsResponse{Success: false, Error: "offset and limit must not be negative", Files: files}, nil
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultRunLogsLimit
+	}
+	if limit > maxRunLogsLimit {
+		limit = maxRunLogsLimit
+	}
+
+	size, err := s.AWSClient.ObjectSize(ctx, s.Bucket, prefix+file)
+	if err != nil {
+		if errors.Is(err, awsclient.ErrObjectNotFound) {
+			return &pb.GetRunLogsResponse{Success: false, Error: fmt.Sprintf("%s not archived for run %s", file, req.RunId), Files: files}, nil
+		}
+		return &pb.GetRunLogsResponse{Success: false, Error: err.Error(), Files: files}, nil
+	}
+
+	resp := &pb.GetRunLogsResponse{
+		Success:    true,
+		File:       file,
+		Files:      files,
+		Offset:     req.Offset,
+		NextOffset: req.Offset,
+		Size:       size,
+		Eof:        true,
+	}
+	if req.Offset < size {
+		content, err := s.AWSClient.GetObjectRange(ctx, s.Bucket, prefix+file, req.Offset, limit)
+		if err != nil {
+			return &pb.GetRunLogsResponse{Success: false, Error: err.Error(), Files: files}, nil
+		}
+		resp.Content = content
+		resp.NextOffset = req.Offset + int64(len(content))
+		resp.Eof = resp.NextOffset >= size
+	}
+	return resp, nil
+}
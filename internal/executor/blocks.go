@@ -0,0 +1,236 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"terraform-executor/pkg/utils"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// loadMainTf returns main.tf of the project, it doesn't exist if the project has no code yet
+func (s *ExecutorService) loadMainTf(ctx context.Context, userId, project string) (*storedFile, error) {
+	return s.store.Get(ctx, userId, project, "main.tf")
+}
+
+// saveMainTf stores main.tf loaded by loadMainTf, it fails with a conflict if it changed since
+func (s *ExecutorService) saveMainTf(ctx context.Context, userId, project string, mainTf *storedFile, content string) error {
+	return s.store.Put(ctx, userId, project, "main.tf", mainTf, content)
+}
+
+// findBlock returns the block declaring an address, blocks that may be repeated must be unique to be found
+func findBlock(blocks []utils.Block, address string) (utils.Block, bool, error) {
+	var found []utils.Block
+	for _, b := range blocks {
+		if b.Address == address {
+			found = append(found, b)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return utils.Block{}, false, nil
+	case 1:
+		return found[0], true, nil
+	default:
+		return utils.Block{}, false, fmt.Errorf("%s is declared by %d blocks", address, len(found))
+	}
+}
+
+// blockToProto converts a block, its source is only included when src is set
+func blockToProto(b utils.Block, src []byte) *pb.Block {
+	block := &pb.Block{
+		Type:      b.Type,
+		Labels:    b.Labels,
+		Address:   b.Address,
+		StartLine: int32(b.Range.Start.Line),
+		EndLine:   int32(b.Range.End.Line),
+	}
+	if src != nil {
+		block.Code = b.Source(src)
+	}
+	return block
+}
+
+// ListBlocks lists the top-level blocks of main.tf.
+func (s *ExecutorService) ListBlocks(ctx context.Context, req *pb.ListBlocksRequest) (*pb.ListBlocksResponse, error) {
+	mainTf, err := s.loadMainTf(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.ListBlocksResponse{Success: false, Error: err.Error()}, nil
+	}
+	blocks, diags := utils.ParseBlocks("main.tf", []byte(mainTf.Content))
+	if diags.HasErrors() {
+		return &pb.ListBlocksResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid main.tf: %v", diags.Error()),
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
+	}
+
+	resp := &pb.ListBlocksResponse{Success: true}
+	for _, b := range blocks {
+		resp.Blocks = append(resp.Blocks, blockToProto(b, nil))
+	}
+	return resp, nil
+}
+
+// GetBlock gets a block of main.tf by its address.
+func (s *ExecutorService) GetBlock(ctx context.Context, req *pb.GetBlockRequest) (*pb.GetBlockResponse, error) {
+	mainTf, err := s.loadMainTf(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetBlockResponse{Success: false, Error: err.Error()}, nil
+	}
+	src := []byte(mainTf.Content)
+	blocks, diags := utils.ParseBlocks("main.tf", src)
+	if diags.HasErrors() {
+		return &pb.GetBlockResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid main.tf: %v", diags.Error()),
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
+	}
+
+	block, ok, err := findBlock(blocks, req.Address)
+	if err != nil {
+		return &pb.GetBlockResponse{Success: false, Error: err.Error()}, nil
+	}
+	if !ok {
+		return &pb.GetBlockResponse{Success: false, Error: fmt.Sprintf("block %s not found", req.Address)}, nil
+	}
+	return &pb.GetBlockResponse{Success: true, Block: blockToProto(block, src)}, nil
+}
+
+// UpsertBlock replaces the block of main.tf declaring the same address as the code, or appends the code.
+// Formatting and comments of the other blocks are kept as they are.
+func (s *ExecutorService) UpsertBlock(ctx context.Context, req *pb.UpsertBlockRequest) (*pb.UpsertBlockResponse, error) {
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.UpsertBlockResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	// the code must be a single block, its address selects the block to replace
+	code, diags := utils.ParseBlocks(codeFilename, []byte(req.Code))
+	if !diags.HasErrors() && len(code) != 1 {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Expected exactly one block",
+			Detail:   fmt.Sprintf("The code declares %d blocks.", len(code)),
+		})
+	}
+	if !diags.HasErrors() {
+		file, _ := hclsyntax.ParseConfig([]byte(req.Code), codeFilename, hcl.InitialPos)
+		for _, attr := range file.Body.(*hclsyntax.Body).Attributes {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unexpected attribute",
+				Detail:   fmt.Sprintf("The code must only declare a block, found attribute %s.", attr.Name),
+				Subject:  attr.NameRange.Ptr(),
+			})
+		}
+	}
+	if diags.HasErrors() {
+		return &pb.UpsertBlockResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid code: %v", diags.Error()),
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
+	}
+
+	// resp is set once main.tf is stored, or to the rejection of an invalid main.tf
+	var resp *pb.UpsertBlockResponse
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "UpsertBlock"}, func() error {
+		mainTf, err := s.loadMainTf(ctx, req.UserId, req.Project)
+		if err != nil {
+			return err
+		}
+		src := []byte(mainTf.Content)
+		blocks, diags := utils.ParseBlocks("main.tf", src)
+		if diags.HasErrors() {
+			resp = &pb.UpsertBlockResponse{
+				Success:     false,
+				Error:       fmt.Sprintf("invalid main.tf: %v", diags.Error()),
+				Diagnostics: s.hclDiagnostics(req.Project, diags),
+			}
+			return nil
+		}
+		address := code[0].Address
+		existing, found, err := findBlock(blocks, address)
+		if err != nil {
+			return err
+		}
+		if found {
+			src = utils.ReplaceBlock(src, existing, req.Code)
+		} else {
+			src = utils.AppendBlock(src, req.Code)
+		}
+
+		// report the stored block with its position in main.tf
+		blocks, diags = utils.ParseBlocks("main.tf", src)
+		if diags.HasErrors() {
+			resp = &pb.UpsertBlockResponse{
+				Success:     false,
+				Error:       fmt.Sprintf("invalid main.tf: %v", diags.Error()),
+				Diagnostics: s.hclDiagnostics(req.Project, diags),
+			}
+			return nil
+		}
+		stored, _, _ := findBlock(blocks, address)
+
+		if err := s.saveMainTf(ctx, req.UserId, req.Project, mainTf, string(src)); err != nil {
+			return err
+		}
+		resp = &pb.UpsertBlockResponse{Success: true, Created: !found, Block: blockToProto(stored, src)}
+		return nil
+	})
+	if err != nil {
+		return &pb.UpsertBlockResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	if resp.Success {
+		resp.Revision = revision
+	}
+	return resp, nil
+}
+
+// RemoveBlock removes a block of main.tf by its address.
+func (s *ExecutorService) RemoveBlock(ctx context.Context, req *pb.RemoveBlockRequest) (*pb.RemoveBlockResponse, error) {
+	// resp is set to the rejection of a missing block or an invalid main.tf
+	var resp *pb.RemoveBlockResponse
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "RemoveBlock"}, func() error {
+		mainTf, err := s.loadMainTf(ctx, req.UserId, req.Project)
+		if err != nil {
+			return err
+		}
+		src := []byte(mainTf.Content)
+		blocks, diags := utils.ParseBlocks("main.tf", src)
+		if diags.HasErrors() {
+			resp = &pb.RemoveBlockResponse{
+				Success:     false,
+				Error:       fmt.Sprintf("invalid main.tf: %v", diags.Error()),
+				Diagnostics: s.hclDiagnostics(req.Project, diags),
+			}
+			return nil
+		}
+
+		block, ok, err := findBlock(blocks, req.Address)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			resp = &pb.RemoveBlockResponse{Success: false, Error: fmt.Sprintf("block %s not found", req.Address)}
+			return nil
+		}
+		content := string(utils.RemoveBlock(src, block))
+		if strings.TrimSpace(content) == "" {
+			content = ""
+		}
+		return s.saveMainTf(ctx, req.UserId, req.Project, mainTf, content)
+	})
+	if err != nil {
+		return &pb.RemoveBlockResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	if resp != nil {
+		return resp, nil
+	}
+	return &pb.RemoveBlockResponse{Success: true, Revision: revision}, nil
+}
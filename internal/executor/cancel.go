@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultCancelGracePeriod is the time terraform gets to stop after SIGINT
+const defaultCancelGracePeriod = 30 * time.Second
+
+// markRunCancelled records who cancelled a run. It is kept as an annotation so
+// the monitor of the run can't overwrite it when it saves the record.
+func (s *ExecutorService) markRunCancelled(ctx context.Context, r *run, cancelledBy string) error {
+	cm, err := s.K8sClient.GetConfigMap(ctx, r.UserID, runConfigMapName(r.Project, r.ID))
+	if err != nil {
+		return fmt.Errorf("failed to get run: %v", err)
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	cm.Annotations["cancelled-by"] = cancelledBy
+	if err := s.K8sClient.UpdateConfigMap(ctx, r.UserID, cm); err != nil {
+		return fmt.Errorf("failed to mark run as cancelled: %v", err)
+	}
+	r.CancelledBy = cancelledBy
+	return nil
+}
+
+// cancelRun interrupts terraform, deletes the runner Job after the grace period and records the cancellation
+func (s *ExecutorService) cancelRun(ctx context.Context, userId, project, runId, cancelledBy string, grace time.Duration) error {
+	r, err := s.getRun(ctx, userId, project, runId)
+	if err != nil {
+		return err
+	}
+	if r.finished() {
+		return fmt.Errorf("run %s already %s", runId, r.Phase)
+	}
+	if cancelledBy == "" {
+		cancelledBy = "unknown"
+	}
+
+	if r.Phase == runPhaseQueued {
+		// take the scheduler lock so the run can't be started while it is cancelled
+		lock, err := s.waitLock(ctx, schedulerLockName)
+		if err != nil {
+			return err
+		}
+		r, err = s.getRun(ctx, userId, project, runId)
+		if err == nil && r.Phase == runPhaseQueued {
+			if err = s.markRunCancelled(ctx, r, cancelledBy); err == nil {
+				s.finishRun(ctx, r, runPhaseCancelled, "")
+			}
+		}
+		s.releaseLock(ctx, lock)
+		if err != nil {
+			return err
+		}
+		if r.Phase == runPhaseCancelled {
+			return s.schedule(ctx)
+		}
+		// the run was started in between, cancel its job
+	}
+
+	if err := s.markRunCancelled(ctx, r, cancelledBy); err != nil {
+		return err
+	}
+
+	if r.JobName != "" {
+		pod, err := s.K8sClient.GetJobPod(ctx, r.UserID, r.JobName)
+		if err == nil && pod.Status.Phase == corev1.PodRunning {
+			// SIGINT lets terraform release the state lock and write partial state
+			if _, err := s.K8sClient.ExecInPod(ctx, r.UserID, pod.Name, "runner", []string{"/bin/sh", "-c", "pkill -INT " + r.binary()}); err != nil {
+				fmt.Printf("⚠️ Failed to interrupt terraform in pod %s: %v\n", pod.Name, err)
+			} else {
+				s.waitForPodExit(ctx, r.UserID, r.JobName, grace)
+			}
+		}
+
+		// the logs are gone with the Job
+		s.archiveRunLogs(ctx, r)
+		if err := s.K8sClient.DeleteJob(ctx, r.UserID, r.JobName); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete job: %v", err)
+		}
+	}
+
+	// the monitor of the run records the cancellation too, only finish runs it didn't finish yet
+	if current, err := s.getRun(ctx, userId, project, runId); err == nil && !current.finished() {
+		s.finishRun(ctx, current, runPhaseCancelled, "")
+	}
+	return nil
+}
+
+// waitForPodExit waits until the runner container of the job stopped or the grace period elapsed
+func (s *ExecutorService) waitForPodExit(ctx context.Context, namespace, jobName string, grace time.Duration) {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	deadline := time.After(grace)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			fmt.Printf("⏳ Grace period for job %s elapsed\n", jobName)
+			return
+		case <-ticker.C:
+			pod, err := s.K8sClient.GetJobPod(ctx, namespace, jobName)
+			if err != nil {
+				return
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name == "runner" && status.State.Terminated != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// CancelRun cancels a run, letting terraform stop gracefully before its Job is deleted.
+func (s *ExecutorService) CancelRun(ctx context.Context, req *pb.CancelRunRequest) (*pb.CancelRunResponse, error) {
+	grace := defaultCancelGracePeriod
+	if req.GracePeriodSeconds > 0 {
+		grace = time.Duration(req.GracePeriodSeconds) * time.Second
+	}
+	if err := s.cancelRun(ctx, req.UserId, req.Project, req.RunId, req.CancelledBy, grace); err != nil {
+		return &pb.CancelRunResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.CancelRunResponse{Success: true}, nil
+}
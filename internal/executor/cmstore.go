@@ -0,0 +1,277 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"terraform-executor/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const storeConfigMap = "configmap"
+
+// configMapStore keeps each of projectFiles in its own ConfigMap, like <project>.main.tf,
+// and the other files in <project>.files. Revisions hold their files inline.
+type configMapStore struct {
+	k8s *k8s.K8sClient
+}
+
+func (c *configMapStore) Name() string {
+	return storeConfigMap
+}
+
+// filesConfigMapName returns the ConfigMap holding the files of a project other than projectFiles
+func filesConfigMapName(project string) string {
+	return fmt.Sprintf("%s.files", project)
+}
+
+// fileConfigMapName returns the ConfigMap holding a file of a project
+func fileConfigMapName(project, p string) string {
+	if slices.Contains(projectFiles, p) {
+		return fmt.Sprintf("%s.%s", project, p)
+	}
+	return filesConfigMapName(project)
+}
+
+func (c *configMapStore) ConfigMap(project, p string) string {
+	return fileConfigMapName(project, p)
+}
+
+func (c *configMapStore) Get(ctx context.Context, namespace, project, p string) (*storedFile, error) {
+	cm, err := c.k8s.GetConfigMap(ctx, namespace, fileConfigMapName(project, p))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return &storedFile{}, nil
+		}
+		return nil, fmt.Errorf("failed to get ConfigMap: %w", err)
+	}
+	content, ok := cm.Data[configMapKey(p)]
+	return &storedFile{Content: content, Exists: ok, Version: cm.ResourceVersion}, nil
+}
+
+func (c *configMapStore) Put(ctx context.Context, namespace, project, p string, prev *storedFile, content string) error {
+	name := fileConfigMapName(project, p)
+	if prev.Version == "" {
+		// creating a ConfigMap created since fails with AlreadyExists
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       map[string]string{configMapKey(p): content},
+		}
+		if err := c.k8s.CreateConfigMap(ctx, namespace, cm); err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		return nil
+	}
+	cm, err := c.k8s.GetConfigMap(ctx, namespace, name)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return fmt.Errorf("%w: ConfigMap %s was deleted", errStaleFile, name)
+		}
+		return fmt.Errorf("failed to get ConfigMap: %w", err)
+	}
+	if cm.ResourceVersion != prev.Version {
+		return fmt.Errorf("%w: ConfigMap %s was updated", errStaleFile, name)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[configMapKey(p)] = content
+	// the update fails with a conflict if the ConfigMap changed since it was read
+	if err := c.k8s.UpdateConfigMap(ctx, namespace, cm); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes a file, the ConfigMap of the other files is deleted with its last file
+func (c *configMapStore) Delete(ctx context.Context, namespace, project, p string) error {
+	if slices.Contains(projectFiles, p) {
+		if err := c.k8s.DeleteConfigMap(ctx, namespace, fileConfigMapName(project, p)); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ConfigMap: %w", err)
+		}
+		return nil
+	}
+	cm, err := c.k8s.GetConfigMap(ctx, namespace, filesConfigMapName(project))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get ConfigMap: %w", err)
+	}
+	delete(cm.Data, fileKey(p))
+	if len(cm.Data) == 0 {
+		if err := c.k8s.DeleteConfigMap(ctx, namespace, cm.Name); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ConfigMap: %w", err)
+		}
+		return nil
+	}
+	if err := c.k8s.UpdateConfigMap(ctx, namespace, cm); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	return nil
+}
+
+func (c *configMapStore) List(ctx context.Context, namespace, project string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, file := range projectFiles {
+		cm, err := c.k8s.GetConfigMap(ctx, namespace, fileConfigMapName(project, file))
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get ConfigMap for %s: %w", file, err)
+		}
+		files[file] = cm.Data[file]
+	}
+	cm, err := c.k8s.GetConfigMap(ctx, namespace, filesConfigMapName(project))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return files, nil
+		}
+		return nil, fmt.Errorf("failed to get ConfigMap for files: %w", err)
+	}
+	for key, content := range cm.Data {
+		if p, ok := filePath(key); ok {
+			files[p] = content
+		}
+	}
+	return files, nil
+}
+
+// putData sets keys of a ConfigMap, creating it if it doesn't exist
+func (c *configMapStore) putData(ctx context.Context, namespace, name string, data map[string]string) error {
+	cm, err := c.k8s.GetConfigMap(ctx, namespace, name)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap: %w", err)
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Data:       data,
+		}
+		if err := c.k8s.CreateConfigMap(ctx, namespace, cm); err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	for key, value := range data {
+		cm.Data[key] = value
+	}
+	if err := c.k8s.UpdateConfigMap(ctx, namespace, cm); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	return nil
+}
+
+func (c *configMapStore) Replace(ctx context.Context, namespace, project string, files map[string]string) error {
+	for _, file := range projectFiles {
+		content, ok := files[file]
+		if !ok {
+			if err := c.Delete(ctx, namespace, project, file); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.putData(ctx, namespace, fileConfigMapName(project, file), map[string]string{file: content}); err != nil {
+			return err
+		}
+	}
+
+	others := make(map[string]string)
+	for p, content := range files {
+		if !slices.Contains(projectFiles, p) {
+			others[fileKey(p)] = content
+		}
+	}
+	name := filesConfigMapName(project)
+	cm, err := c.k8s.GetConfigMap(ctx, namespace, name)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get ConfigMap: %w", err)
+	}
+	switch {
+	case err != nil && len(others) == 0:
+		return nil
+	case err != nil:
+		return c.putData(ctx, namespace, name, others)
+	case len(others) == 0:
+		if err := c.k8s.DeleteConfigMap(ctx, namespace, name); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ConfigMap: %w", err)
+		}
+		return nil
+	}
+	cm.Data = others
+	if err := c.k8s.UpdateConfigMap(ctx, namespace, cm); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// Clear deletes the ConfigMaps of the files, the revisions hold their own files
+func (c *configMapStore) Clear(ctx context.Context, namespace, project string) error {
+	names := []string{filesConfigMapName(project)}
+	for _, file := range projectFiles {
+		names = append(names, fileConfigMapName(project, file))
+	}
+	for _, name := range names {
+		if err := c.k8s.DeleteConfigMap(ctx, namespace, name); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete ConfigMap %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (c *configMapStore) Snapshot(ctx context.Context, namespace, project, hash string, files map[string]string) (map[string]string, error) {
+	return encodeFiles(files), nil
+}
+
+func (c *configMapStore) ReadSnapshot(ctx context.Context, namespace, project, hash string) (map[string]string, error) {
+	return nil, fmt.Errorf("the %s store keeps no snapshots", storeConfigMap)
+}
+
+func (c *configMapStore) DeleteSnapshot(ctx context.Context, namespace, project, hash string) error {
+	return nil
+}
+
+func (c *configMapStore) Mount(ctx context.Context, namespace, project string, rev *revision, pod *runnerPod) error {
+	mountRevision(project, rev, pod)
+	return nil
+}
+
+// mountRevision mounts the files held inline by a revision ConfigMap, each at its path in the working directory
+func mountRevision(project string, rev *revision, pod *runnerPod) {
+	pod.Volumes = append(pod.Volumes, corev1.Volume{
+		Name: "config",
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: revisionConfigMapName(project, rev.Number),
+				},
+			},
+		},
+	})
+	for _, p := range rev.Paths {
+		pod.Mounts = append(pod.Mounts, corev1.VolumeMount{
+			Name:      "config",
+			MountPath: fmt.Sprintf("/root/%s", p),
+			SubPath:   configMapKey(p),
+		})
+	}
+}
+
+// Limits keeps a revision, which holds all files in one ConfigMap, below the 1 MiB object limit
+func (c *configMapStore) Limits() fileLimits {
+	return fileLimits{
+		File:  256 << 10,
+		Total: 768 << 10,
+	}
+}
+
+func (c *configMapStore) Credentials() bool {
+	return false
+}
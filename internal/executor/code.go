@@ -2,72 +2,68 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	pb "terraform-executor/api/proto"
 	"terraform-executor/pkg/utils"
 
+	"github.com/hashicorp/hcl/v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// AppendCode appends the provided code to the main.tf file in the workspace directory and ConfigMap.
+// AppendCode appends the provided code to the main.tf file of the project.
+// The code and the resulting file are parsed first, invalid code is rejected with its diagnostics.
 func (s *ExecutorService) AppendCode(ctx context.Context, req *pb.AppendCodeRequest) (*pb.AppendCodeResponse, error) {
 	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
 		return &pb.AppendCodeResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "main.tf")
-
-	// Get existing ConfigMap or create new one
-	cm, err := s.K8sClient.GetConfigMap(ctx, req.UserId, configMapName)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			// Create new ConfigMap
-			cm = &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: configMapName,
-				},
-				Data: map[string]string{
-					"main.tf": req.Code,
-				},
-			}
-			if err := s.K8sClient.CreateConfigMap(ctx, req.UserId, cm); err != nil {
-				return &pb.AppendCodeResponse{Success: false, Error: fmt.Sprintf("failed to create ConfigMap: %v", err)}, nil
-			}
-		} else {
-			return &pb.AppendCodeResponse{Success: false, Error: fmt.Sprintf("failed to get ConfigMap: %v", err)}, nil
+	var diags hcl.Diagnostics
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "AppendCode"}, func() error {
+		// Get existing main.tf or create new one
+		mainTf, err := s.store.Get(ctx, req.UserId, req.Project, "main.tf")
+		if err != nil {
+			return err
 		}
-	} else {
-		// Update existing ConfigMap
-		if cm.Data == nil {
-			cm.Data = make(map[string]string)
+		content := req.Code
+		if mainTf.Exists {
+			content = mainTf.Content + "\n" + req.Code
 		}
-		cm.Data["main.tf"] = cm.Data["main.tf"] + "\n" + req.Code
-		if err := s.K8sClient.UpdateConfigMap(ctx, req.UserId, cm); err != nil {
-			return &pb.AppendCodeResponse{Success: false, Error: fmt.Sprintf("failed to update ConfigMap: %v", err)}, nil
+		if diags = checkCode(req.Code, content, req.RejectDuplicateBlocks); diags.HasErrors() {
+			return nil
 		}
+		return s.store.Put(ctx, req.UserId, req.Project, "main.tf", mainTf, content)
+	})
+	if err != nil {
+		return &pb.AppendCodeResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	if diags.HasErrors() {
+		return &pb.AppendCodeResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid code: %v", diags.Error()),
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
 	}
-	return &pb.AppendCodeResponse{Success: true}, nil
+	return &pb.AppendCodeResponse{Success: true, Revision: revision}, nil
 }
 
-// Clear removes all created files in the workspace directory and ConfigMap.
+// Clear removes main.tf of the project.
 func (s *ExecutorService) ClearCode(ctx context.Context, req *pb.ClearCodeRequest) (*pb.ClearCodeResponse, error) {
 	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
 		return &pb.ClearCodeResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "main.tf")
-
-	// Delete ConfigMap
-	if err := s.K8sClient.DeleteConfigMap(ctx, req.UserId, configMapName); err != nil {
-		if !errors.IsNotFound(err) {
-			return &pb.ClearCodeResponse{Success: false, Error: fmt.Sprintf("failed to delete ConfigMap: %v", err)}, nil
-		}
-		// Ignore not found error
+	// Delete main.tf
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "ClearCode"}, func() error {
+		return s.store.Delete(ctx, req.UserId, req.Project, "main.tf")
+	})
+	if err != nil {
+		return &pb.ClearCodeResponse{Success: false, Error: err.Error()}, mutationStatus(err)
 	}
-	return &pb.ClearCodeResponse{Success: true}, nil
+	return &pb.ClearCodeResponse{Success: true, Revision: revision}, nil
 }
 
 // Add providers to the Terraform configuration
@@ -92,12 +88,22 @@ func (s *ExecutorService) AddProviders(ctx context.Context, req *pb.AddProviders
 		providers = append(providers, provider)
 	}
 
+	// Keep required_version in sync with the runtime of the project
+	rt, err := s.getRuntime(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.AddProvidersResponse{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
 	// Fill the struct with the provider data
 	data := utils.TerraformTemplateData{
-		Bucket:    s.Bucket,
-		UserID:    req.UserId,
-		Project:   req.Project,
-		Providers: providers,
+		Bucket:          s.Bucket,
+		UserID:          req.UserId,
+		Project:         req.Project,
+		Providers:       providers,
+		RequiredVersion: rt.requiredVersion(),
 	}
 
 	// Generate the Terraform configuration
@@ -109,45 +115,19 @@ func (s *ExecutorService) AddProviders(ctx context.Context, req *pb.AddProviders
 		}, err
 	}
 
-	// Create or update ConfigMap
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "versions.tf")
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: configMapName,
-		},
-		Data: map[string]string{
-			"versions.tf": config,
-		},
-	}
-
-	existingCm, err := s.K8sClient.GetConfigMap(ctx, req.UserId, configMapName)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			// Create new ConfigMap
-			if err := s.K8sClient.CreateConfigMap(ctx, req.UserId, cm); err != nil {
-				return &pb.AddProvidersResponse{
-					Success: false,
-					Error:   fmt.Sprintf("failed to create ConfigMap: %v", err),
-				}, err
-			}
-		} else {
-			return &pb.AddProvidersResponse{
-				Success: false,
-				Error:   fmt.Sprintf("failed to get ConfigMap: %v", err),
-			}, err
-		}
-	} else {
-		// Update existing ConfigMap
-		existingCm.Data = cm.Data
-		if err := s.K8sClient.UpdateConfigMap(ctx, req.UserId, existingCm); err != nil {
-			return &pb.AddProvidersResponse{
-				Success: false,
-				Error:   fmt.Sprintf("failed to update ConfigMap: %v", err),
-			}, nil
+	// Create or replace versions.tf
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "AddProviders"}, func() error {
+		versions, err := s.store.Get(ctx, req.UserId, req.Project, "versions.tf")
+		if err != nil {
+			return err
 		}
+		return s.store.Put(ctx, req.UserId, req.Project, "versions.tf", versions, config)
+	})
+	if err != nil {
+		return &pb.AddProvidersResponse{Success: false, Error: err.Error()}, mutationStatus(err)
 	}
 
-	return &pb.AddProvidersResponse{Success: true}, nil
+	return &pb.AddProvidersResponse{Success: true, Revision: revision}, nil
 }
 
 // Clear providers from the Terraform configuration
@@ -156,15 +136,19 @@ func (s *ExecutorService) ClearProviders(ctx context.Context, req *pb.ClearProvi
 		return &pb.ClearProvidersResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	// Remove ConfigMap
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "versions.tf")
-	if err := s.K8sClient.DeleteConfigMap(ctx, req.UserId, configMapName); err != nil {
-		if !errors.IsNotFound(err) {
-			return &pb.ClearProvidersResponse{Success: false, Error: fmt.Sprintf("failed to delete ConfigMap: %v", err)}, nil
-		}
-		// Ignore not found error
+	// Remove versions.tf
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "ClearProviders"}, func() error {
+		return s.store.Delete(ctx, req.UserId, req.Project, "versions.tf")
+	})
+	if err != nil {
+		return &pb.ClearProvidersResponse{Success: false, Error: err.Error()}, mutationStatus(err)
 	}
-	return &pb.ClearProvidersResponse{Success: true}, nil
+	return &pb.ClearProvidersResponse{Success: true, Revision: revision}, nil
+}
+
+// envSecretName returns the name of the Secret holding the secret env variables of a project
+func envSecretName(project string) string {
+	return fmt.Sprintf("%s.%s", project, "env")
 }
 
 // Add secret env variables to the Terraform configuration
@@ -173,11 +157,18 @@ func (s *ExecutorService) AddSecretEnv(ctx context.Context, req *pb.AddSecretEnv
 		return &pb.AddSecretEnvResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	secretName := fmt.Sprintf("%s.%s", req.Project, "env")
-	// Get existing Secret or create new one
-	secret, err := s.K8sClient.GetSecret(ctx, req.UserId, secretName)
-	if err != nil {
-		if errors.IsNotFound(err) {
+	secretName := envSecretName(req.Project)
+	var etag string
+	err := retryMutation(req.ExpectedEtag != "", func() error {
+		// Get existing Secret or create new one
+		secret, err := s.K8sClient.GetSecret(ctx, req.UserId, secretName)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to get Secret: %w", err)
+			}
+			if err := checkEtag(req.ExpectedEtag, ""); err != nil {
+				return err
+			}
 			// Create new Secret from the req.Secrets
 			secret = &corev1.Secret{
 				ObjectMeta: metav1.ObjectMeta{
@@ -189,24 +180,30 @@ func (s *ExecutorService) AddSecretEnv(ctx context.Context, req *pb.AddSecretEnv
 				secret.StringData[s.Name] = s.Value
 			}
 			if err := s.K8sClient.CreateSecret(ctx, req.UserId, secret); err != nil {
-				return &pb.AddSecretEnvResponse{Success: false, Error: fmt.Sprintf("failed to create Secret: %v", err)}, nil
+				return fmt.Errorf("failed to create Secret: %w", err)
 			}
 		} else {
-			return &pb.AddSecretEnvResponse{Success: false, Error: fmt.Sprintf("failed to get Secret: %v", err)}, nil
-		}
-	} else {
-		// Update existing Secret by adding new key-value pairs
-		if secret.StringData == nil {
-			secret.StringData = make(map[string]string)
-		}
-		for _, s := range req.Secrets {
-			secret.StringData[s.Name] = s.Value
-		}
-		if err := s.K8sClient.UpdateSecret(ctx, req.UserId, secret); err != nil {
-			return &pb.AddSecretEnvResponse{Success: false, Error: fmt.Sprintf("failed to update Secret: %v", err)}, nil
+			if err := checkEtag(req.ExpectedEtag, secret.ResourceVersion); err != nil {
+				return err
+			}
+			// Update existing Secret by adding new key-value pairs
+			if secret.StringData == nil {
+				secret.StringData = make(map[string]string)
+			}
+			for _, s := range req.Secrets {
+				secret.StringData[s.Name] = s.Value
+			}
+			if err := s.K8sClient.UpdateSecret(ctx, req.UserId, secret); err != nil {
+				return fmt.Errorf("failed to update Secret: %w", err)
+			}
 		}
+		etag = secret.ResourceVersion
+		return nil
+	})
+	if err != nil {
+		return &pb.AddSecretEnvResponse{Success: false, Error: err.Error()}, mutationStatus(err)
 	}
-	return &pb.AddSecretEnvResponse{Success: true}, nil
+	return &pb.AddSecretEnvResponse{Success: true, Etag: etag}, nil
 }
 
 // Clear secret env variables from the Terraform configuration
@@ -216,7 +213,20 @@ func (s *ExecutorService) ClearSecretEnv(ctx context.Context, req *pb.ClearSecre
 	}
 
 	// Remove Secret
-	secretName := fmt.Sprintf("%s.%s", req.Project, "env")
+	secretName := envSecretName(req.Project)
+	if req.ExpectedEtag != "" {
+		secret, err := s.K8sClient.GetSecret(ctx, req.UserId, secretName)
+		if err != nil && !errors.IsNotFound(err) {
+			return &pb.ClearSecretEnvResponse{Success: false, Error: fmt.Sprintf("failed to get Secret: %v", err)}, nil
+		}
+		var resourceVersion string
+		if err == nil {
+			resourceVersion = secret.ResourceVersion
+		}
+		if err := checkEtag(req.ExpectedEtag, resourceVersion); err != nil {
+			return &pb.ClearSecretEnvResponse{Success: false, Error: err.Error()}, err
+		}
+	}
 	if err := s.K8sClient.DeleteSecret(ctx, req.UserId, secretName); err != nil {
 		if !errors.IsNotFound(err) {
 			return &pb.ClearSecretEnvResponse{Success: false, Error: fmt.Sprintf("failed to delete Secret: %v", err)}, nil
@@ -226,45 +236,33 @@ func (s *ExecutorService) ClearSecretEnv(ctx context.Context, req *pb.ClearSecre
 	return &pb.ClearSecretEnvResponse{Success: true}, nil
 }
 
-// Add secret terraform variables to the Terraform configuration
+// Add secret terraform variables to the Terraform configuration. The variables are declared
+// as sensitive strings in variables.tf, see SetVariables.
 func (s *ExecutorService) AddSecretVar(ctx context.Context, req *pb.AddSecretVarRequest) (*pb.AddSecretVarResponse, error) {
 	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
 		return &pb.AddSecretVarResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "variables.tf")
-	// Get existing ConfigMap or create new one
-	cm, err := s.K8sClient.GetConfigMap(ctx, req.UserId, configMapName)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			// Create new ConfigMap
-			cm = &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: configMapName,
-				},
-				Data: map[string]string{
-					"variables.tf": "",
-				},
-			}
-			if err := s.K8sClient.CreateConfigMap(ctx, req.UserId, cm); err != nil {
-				return &pb.AddSecretVarResponse{Success: false, Error: fmt.Sprintf("failed to create ConfigMap: %v", err)}, nil
-			}
-		} else {
-			return &pb.AddSecretVarResponse{Success: false, Error: fmt.Sprintf("failed to get ConfigMap: %v", err)}, nil
+	vars := make([]*pb.Variable, 0, len(req.Secrets))
+	for _, secret := range req.Secrets {
+		value, err := json.Marshal(secret.Value)
+		if err != nil {
+			return &pb.AddSecretVarResponse{Success: false, Error: err.Error()}, nil
 		}
+		vars = append(vars, &pb.Variable{Name: secret.Name, Type: "string", Sensitive: true, ValueJson: string(value)})
 	}
-
-	// Update existing ConfigMap
-	varsContent := cm.Data["variables.tf"]
-	for _, secret := range req.Secrets {
-		varDef := fmt.Sprintf("variable \"%s\" {\n  type = string\n  default = \"%s\"\n}\n", secret.Name, secret.Value)
-		varsContent += varDef
+	values, err := checkVariables(vars)
+	if err != nil {
+		return &pb.AddSecretVarResponse{Success: false, Error: err.Error()}, nil
 	}
-	cm.Data["variables.tf"] = varsContent
-	if err := s.K8sClient.UpdateConfigMap(ctx, req.UserId, cm); err != nil {
-		return &pb.AddSecretVarResponse{Success: false, Error: fmt.Sprintf("failed to update ConfigMap: %v", err)}, nil
+
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "AddSecretVar"}, func() error {
+		return s.setVariables(ctx, req.UserId, req.Project, vars, values)
+	})
+	if err != nil {
+		return &pb.AddSecretVarResponse{Success: false, Error: err.Error()}, mutationStatus(err)
 	}
-	return &pb.AddSecretVarResponse{Success: true}, nil
+	return &pb.AddSecretVarResponse{Success: true, Revision: revision}, nil
 }
 
 // Clear secret variables from the Terraform configuration
@@ -273,15 +271,17 @@ func (s *ExecutorService) ClearSecretVars(ctx context.Context, req *pb.ClearSecr
 		return &pb.ClearSecretVarsResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	// Remove ConfigMap
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "variables.tf")
-	if err := s.K8sClient.DeleteConfigMap(ctx, req.UserId, configMapName); err != nil {
-		if !errors.IsNotFound(err) {
-			return &pb.ClearSecretVarsResponse{Success: false, Error: fmt.Sprintf("failed to delete ConfigMap: %v", err)}, nil
+	// Remove variables.tf and the values of the variables
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "ClearSecretVars"}, func() error {
+		if err := s.store.Delete(ctx, req.UserId, req.Project, "variables.tf"); err != nil {
+			return err
 		}
-		// Ignore not found error
+		return s.clearVarValues(ctx, req.UserId, req.Project)
+	})
+	if err != nil {
+		return &pb.ClearSecretVarsResponse{Success: false, Error: err.Error()}, mutationStatus(err)
 	}
-	return &pb.ClearSecretVarsResponse{Success: true}, nil
+	return &pb.ClearSecretVarsResponse{Success: true, Revision: revision}, nil
 }
 
 // Create a new project with the provided name.
@@ -310,6 +310,11 @@ func (s *ExecutorService) DeleteProject(ctx context.Context, req *pb.DeleteProje
 
 	var errors []string
 
+	// Unbind the git repository first, the files of a bound project can't be cleared
+	if err := s.clearGitBinding(ctx, req.UserId, req.Project); err != nil {
+		errors = append(errors, err.Error())
+	}
+
 	// Clear code
 	if _, err := s.ClearCode(ctx, &pb.ClearCodeRequest{
 		UserId:  req.UserId,
@@ -342,6 +347,26 @@ func (s *ExecutorService) DeleteProject(ctx context.Context, req *pb.DeleteProje
 		errors = append(errors, fmt.Sprintf("failed to clear secret env variables: %v", err))
 	}
 
+	// Remove the other files and the files kept for the revisions
+	if err := s.store.Clear(ctx, req.UserId, req.Project); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Remove runtime setting
+	if err := s.clearRuntime(ctx, req.UserId, req.Project); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Remove the run history with the logs and artifacts of the runs
+	if err := s.deleteRuns(ctx, req.UserId, req.Project); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	// Remove revision history, after the clears above recorded theirs
+	if err := s.deleteRevisions(ctx, req.UserId, req.Project); err != nil {
+		errors = append(errors, err.Error())
+	}
+
 	if len(errors) > 0 {
 		return &pb.DeleteProjectResponse{Success: false, Error: strings.Join(errors, "; ")}, nil
 	}
@@ -349,40 +374,32 @@ func (s *ExecutorService) DeleteProject(ctx context.Context, req *pb.DeleteProje
 	return &pb.DeleteProjectResponse{Success: true}, nil
 }
 
-// GetMainTf returns the content of main.tf from ConfigMap
+// GetMainTf returns the content of main.tf
 func (s *ExecutorService) GetMainTf(ctx context.Context, req *pb.GetMainTfRequest) (*pb.GetMainTfResponse, error) {
 	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
 		return &pb.GetMainTfResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	configMapName := fmt.Sprintf("%s.%s", req.Project, "main.tf")
-
-	// Get ConfigMap
-	cm, err := s.K8sClient.GetConfigMap(ctx, req.UserId, configMapName)
+	mainTf, err := s.store.Get(ctx, req.UserId, req.Project, "main.tf")
 	if err != nil {
-		if errors.IsNotFound(err) {
-			return &pb.GetMainTfResponse{
-				Success: false,
-				Error:   fmt.Sprintf("main.tf does not exist for project %s", req.Project),
-			}, nil
-		}
+		return &pb.GetMainTfResponse{Success: false, Error: err.Error()}, nil
+	}
+	if !mainTf.Exists {
 		return &pb.GetMainTfResponse{
 			Success: false,
-			Error:   fmt.Sprintf("failed to get ConfigMap: %v", err),
+			Error:   fmt.Sprintf("main.tf does not exist for project %s", req.Project),
 		}, nil
 	}
 
-	// Get content from ConfigMap
-	content, ok := cm.Data["main.tf"]
-	if !ok {
-		return &pb.GetMainTfResponse{
-			Success: false,
-			Error:   "main.tf not found in ConfigMap",
-		}, nil
+	// the revision to expect when mutating the content
+	revision, err := s.latestRevision(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetMainTfResponse{Success: false, Error: err.Error()}, nil
 	}
 
 	return &pb.GetMainTfResponse{
-		Success: true,
-		Content: content,
+		Success:  true,
+		Content:  mainTf.Content,
+		Revision: revision,
 	}, nil
 }
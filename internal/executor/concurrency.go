@@ -0,0 +1,111 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// isConflict reports whether a write lost the race against another one: the object changed since
+// it was read, or it was created since it wasn't found
+func isConflict(err error) bool {
+	return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err) || errors.Is(err, errStaleFile)
+}
+
+// claimTTL bounds how long a crashed executor can hold the claim of a revision
+const claimTTL = 2 * time.Minute
+
+// mutationBackoff spaces the attempts of a mutation, long enough for a concurrent mutation of the same
+// project to record its revision
+var mutationBackoff = wait.Backoff{
+	Steps:    20,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.5,
+	Jitter:   0.1,
+	Cap:      time.Second,
+}
+
+// retryMutation runs the get-modify-update of a mutation, it is retried from the get when a write conflicts.
+// A guarded mutation expects the state the caller has read, a conflict means that state is stale
+// and is returned as an Aborted status instead.
+func retryMutation(guarded bool, fn func() error) error {
+	err := retry.OnError(mutationBackoff, func(err error) bool {
+		return !guarded && isConflict(err)
+	}, fn)
+	if guarded && isConflict(err) {
+		return status.Errorf(codes.Aborted, "changed concurrently, read it again and retry: %v", err)
+	}
+	return err
+}
+
+// mutateProject runs a mutation of the project files and returns the revision recording it. With an
+// expected revision, it is aborted when the latest revision of the project is another one. The files
+// of a project bound to a git repository are only changed by SyncFromGit.
+func (s *ExecutorService) mutateProject(ctx context.Context, namespace, project string, expected int64, c change, fn func() error) (int64, error) {
+	binding, err := s.getGitBinding(ctx, namespace, project)
+	if err != nil {
+		return 0, err
+	}
+	if binding != nil {
+		return 0, fmt.Errorf("project %s is bound to git repository %s, change the files there and sync or unbind the project first", project, binding.URL)
+	}
+	return s.mutateRevision(ctx, namespace, project, expected, c, fn)
+}
+
+// mutateRevision runs a mutation like mutateProject, whether or not the project is bound to git.
+// The next revision is claimed before the files change, so two mutations expecting the same revision
+// can't both succeed. The revision is recorded into the claim once the mutation succeeded.
+func (s *ExecutorService) mutateRevision(ctx context.Context, namespace, project string, expected int64, c change, fn func() error) (int64, error) {
+	var claim *corev1.ConfigMap
+	err := retryMutation(expected != 0, func() error {
+		latest, err := s.latestRevision(ctx, namespace, project)
+		if err != nil {
+			return err
+		}
+		if expected != 0 && latest != expected {
+			return status.Errorf(codes.Aborted, "expected revision %d of project %s is stale, the latest revision is %d", expected, project, latest)
+		}
+		if claim, err = s.claimRevision(ctx, namespace, project, latest+1); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			s.releaseRevision(ctx, namespace, claim)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	rev, err := s.recordRevision(ctx, namespace, project, c, claim)
+	if err != nil {
+		// the next mutation may take the number, the revision it records includes this change
+		s.releaseRevision(ctx, namespace, claim)
+		return 0, fmt.Errorf("%s was applied but its revision wasn't recorded, the next change records it: %v", c.Action, err)
+	}
+	return rev.Number, nil
+}
+
+// mutationStatus returns the status of an aborted mutation, other errors are reported in the response
+func mutationStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return nil
+}
+
+// checkEtag aborts a mutation of an object the caller expects at another version, an empty etag skips the check
+func checkEtag(expected, resourceVersion string) error {
+	if expected != "" && expected != resourceVersion {
+		return status.Errorf(codes.Aborted, "expected etag %q is stale, the current one is %q", expected, resourceVersion)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"terraform-executor/internal/awsclient"
+	"terraform-executor/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// errStaleFile is returned by configStore.Put when the file changed since it was read
+var errStaleFile = errors.New("file changed since it was read")
+
+// storedFile is a file read from a configStore, its version guards the write of a new content
+type storedFile struct {
+	Content string
+	Exists  bool
+	Version string // opaque, empty if nothing holding the file exists yet
+}
+
+// runnerPod collects what the runner pod needs to find the project files in its working directory.
+// Init containers may use the aws-creds and workspace volumes of the pod.
+type runnerPod struct {
+	Volumes        []corev1.Volume
+	Mounts         []corev1.VolumeMount // of the runner container
+	InitContainers []corev1.Container
+	Setup          string // shell command run in the working directory before init
+}
+
+// configStore stores the files of the projects. projectFiles and the files put through the file API
+// are addressed by their path relative to the working directory.
+type configStore interface {
+	// Name identifies the store in CONFIG_STORE and in the revisions whose files it holds
+	Name() string
+	// Get returns a file, a missing file has Exists false
+	Get(ctx context.Context, namespace, project, path string) (*storedFile, error)
+	// Put writes a file returned by Get. It fails with a conflict if the file changed since.
+	Put(ctx context.Context, namespace, project, path string, prev *storedFile, content string) error
+	// Delete deletes a file, a missing file is no error
+	Delete(ctx context.Context, namespace, project, path string) error
+	// List returns all files of a project
+	List(ctx context.Context, namespace, project string) (map[string]string, error)
+	// Replace replaces all files of a project, files missing from the set are deleted.
+	// The files are written one by one, callers go through replaceFiles to roll back a failed replace.
+	Replace(ctx context.Context, namespace, project string, files map[string]string) error
+	// Clear deletes all files and snapshots of a project
+	Clear(ctx context.Context, namespace, project string) error
+	// ConfigMap returns the ConfigMap holding a file, empty if files aren't stored in ConfigMaps
+	ConfigMap(project, path string) string
+
+	// Snapshot stores the files of a revision. The returned data is stored in the revision ConfigMap,
+	// nil if the store keeps the snapshot itself.
+	Snapshot(ctx context.Context, namespace, project, hash string, files map[string]string) (map[string]string, error)
+	// ReadSnapshot returns the files of a snapshot the store keeps itself
+	ReadSnapshot(ctx context.Context, namespace, project, hash string) (map[string]string, error)
+	// DeleteSnapshot deletes a snapshot the store keeps itself, once no revision refers to it
+	DeleteSnapshot(ctx context.Context, namespace, project, hash string) error
+
+	// Mount adds the files of a revision to the runner pod
+	Mount(ctx context.Context, namespace, project string, rev *revision, pod *runnerPod) error
+	// Limits returns the default size limits of the files
+	Limits() fileLimits
+	// Credentials reports whether the runner needs the AWS credentials of the user to fetch the files
+	Credentials() bool
+}
+
+// configStoreFromEnv returns the store selected by CONFIG_STORE, configmap (default) or s3
+func configStoreFromEnv(k8sClient *k8s.K8sClient, awsClient *awsclient.AWSClient, bucket, region string) (configStore, error) {
+	switch kind := os.Getenv("CONFIG_STORE"); kind {
+	case "", storeConfigMap:
+		return &configMapStore{k8s: k8sClient}, nil
+	case storeS3:
+		return newS3Store(awsClient, k8sClient, bucket, region), nil
+	default:
+		return nil, fmt.Errorf("invalid CONFIG_STORE %q, must be %s or %s", kind, storeConfigMap, storeS3)
+	}
+}
+
+// replaceFiles replaces all files of a project and restores the previous files if the replace fails
+// partway, the project never keeps a mix of both. Runs can't record the half replaced files meanwhile,
+// the mutation holds the claim of the next revision until the files are recorded.
+func (s *ExecutorService) replaceFiles(ctx context.Context, namespace, project string, files map[string]string) error {
+	prev, err := s.store.List(ctx, namespace, project)
+	if err != nil {
+		return err
+	}
+	err = s.store.Replace(ctx, namespace, project, files)
+	if err == nil {
+		return nil
+	}
+	// the rollback must finish even if the request was canceled
+	if rollbackErr := s.store.Replace(context.WithoutCancel(ctx), namespace, project, prev); rollbackErr != nil {
+		return fmt.Errorf("%w, restoring the previous files failed too, restore the latest revision: %v", err, rollbackErr)
+	}
+	return err
+}
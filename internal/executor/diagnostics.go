@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"terraform-executor/pkg/utils"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// codeFilename names the code of a request in the diagnostics of its own syntax errors
+const codeFilename = "code"
+
+var (
+	// diagnosticHeader matches the first line of a diagnostic printed with -no-color
+	diagnosticHeader = regexp.MustCompile(`^(Error|Warning): (.*)$`)
+	// diagnosticSource matches the source range line of a diagnostic, like `  on main.tf line 3, in resource "x" "y":`
+	diagnosticSource = regexp.MustCompile(`^  on (\S+) line (\d+)`)
+	// diagnosticEnd matches output following a diagnostic, like the progress of init
+	diagnosticEnd = regexp.MustCompile(`^(Initializing |Terraform has |OpenTofu has |(Config|Init|Runner) container logs:)`)
+)
+
+// tfValidate is the output of `terraform validate -json`
+type tfValidate struct {
+	FormatVersion string         `json:"format_version"`
+	Valid         bool           `json:"valid"`
+	Diagnostics   []uiDiagnostic `json:"diagnostics"`
+}
+
+// splitValidateOutput separates the indented JSON object printed by validate -json from the rest of the output
+func splitValidateOutput(logs string) (*tfValidate, string) {
+	start := strings.LastIndex("\n"+logs, "\n{\n")
+	if start < 0 {
+		return nil, logs
+	}
+	dec := json.NewDecoder(strings.NewReader(logs[start:]))
+	var result tfValidate
+	if err := dec.Decode(&result); err != nil || result.FormatVersion == "" {
+		return nil, logs
+	}
+	end := start + int(dec.InputOffset())
+	return &result, logs[:start] + logs[end:]
+}
+
+// collectDiagnostics returns the diagnostics of the runner output. They are decoded from the -json
+// UI output, the human readable diagnostics of commands without -json like init are parsed as a fallback.
+func (s *ExecutorService) collectDiagnostics(project, logs string) []*pb.Diagnostic {
+	var diags []*pb.Diagnostic
+	if result, rest := splitValidateOutput(logs); result != nil {
+		for i := range result.Diagnostics {
+			diags = append(diags, result.Diagnostics[i].toProto())
+		}
+		logs = rest
+	}
+	var text []string
+	for _, line := range strings.Split(logs, "\n") {
+		if event := decodeUIEvent(line); event != nil {
+			if event.Diagnostic != nil {
+				diags = append(diags, event.Diagnostic)
+			}
+			continue
+		}
+		text = append(text, line)
+	}
+	diags = append(diags, parseTextDiagnostics(text)...)
+
+	for _, d := range diags {
+		// files of modules installed by init aren't project files
+		if validateFilePath(d.Filename) == nil {
+			d.ConfigMap = s.store.ConfigMap(project, d.Filename)
+		}
+	}
+	return diags
+}
+
+// parseTextDiagnostics parses diagnostics printed with -no-color:
+//
+//	Error: Unsupported block type
+//
+//	  on main.tf line 3:
+//	   3: resource_foo "bar" {
+//
+//	Blocks of type "resource_foo" are not expected here.
+//
+// Plain output has no columns, the range covers the whole line.
+func parseTextDiagnostics(lines []string) []*pb.Diagnostic {
+	var diags []*pb.Diagnostic
+	var current *pb.Diagnostic
+	var detail []string
+	flush := func() {
+		if current != nil {
+			current.Detail = strings.TrimSpace(strings.Join(detail, "\n"))
+			diags = append(diags, current)
+		}
+		current, detail = nil, nil
+	}
+
+	for _, line := range lines {
+		// diagnostics may be framed by box drawing characters
+		line = strings.TrimRight(line, "\r")
+		if rest, ok := strings.CutPrefix(line, "│"); ok {
+			line = strings.TrimPrefix(rest, " ")
+		}
+		if line == "╷" || line == "╵" {
+			continue
+		}
+
+		if m := diagnosticHeader.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &pb.Diagnostic{Severity: strings.ToLower(m[1]), Summary: m[2]}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if diagnosticEnd.MatchString(line) {
+			flush()
+			continue
+		}
+		if m := diagnosticSource.FindStringSubmatch(line); m != nil && current.Filename == "" {
+			n, _ := strconv.Atoi(m[2])
+			current.Filename = m[1]
+			current.StartLine = int32(n)
+			current.EndLine = int32(n)
+			continue
+		}
+		// skip the source snippet, the detail isn't indented
+		if strings.HasPrefix(line, " ") {
+			continue
+		}
+		detail = append(detail, line)
+	}
+	flush()
+	return diags
+}
+
+// checkCode parses the code of a request and the file resulting from it. Errors of the code itself
+// are reported relative to the code, the ones of the file relative to main.tf.
+func checkCode(code, content string, rejectDuplicates bool) hcl.Diagnostics {
+	if diags := utils.CheckHCL(codeFilename, []byte(code)); diags.HasErrors() {
+		return diags
+	}
+	if rejectDuplicates {
+		return utils.DuplicateBlocks("main.tf", []byte(content))
+	}
+	return utils.CheckHCL("main.tf", []byte(content))
+}
+
+// hclDiagnostics converts the diagnostics of the HCL parser
+func (s *ExecutorService) hclDiagnostics(project string, diags hcl.Diagnostics) []*pb.Diagnostic {
+	out := make([]*pb.Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		diag := &pb.Diagnostic{Severity: "error", Summary: d.Summary, Detail: d.Detail}
+		if d.Severity == hcl.DiagWarning {
+			diag.Severity = "warning"
+		}
+		if d.Subject != nil {
+			diag.Filename = d.Subject.Filename
+			diag.StartLine = int32(d.Subject.Start.Line)
+			diag.StartColumn = int32(d.Subject.Start.Column)
+			diag.EndLine = int32(d.Subject.End.Line)
+			diag.EndColumn = int32(d.Subject.End.Column)
+		}
+		if diag.Filename != codeFilename && validateFilePath(diag.Filename) == nil {
+			diag.ConfigMap = s.store.ConfigMap(project, diag.Filename)
+		}
+		out = append(out, diag)
+	}
+	return out
+}
@@ -0,0 +1,284 @@
+package executor
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"terraform-executor/pkg/utils"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/v2"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// maxPathLength bounds the length of a file path, its encoded ConfigMap key may have at most 253 characters
+const maxPathLength = 189
+
+// reservedDirs are directories of the working directory used by the runner
+var reservedDirs = []string{".terraform", ".terraform.d", ".aws"}
+
+// fileLimits bounds the size of the project files, the defaults depend on the configStore
+type fileLimits struct {
+	File  int64 // size of one file
+	Total int64 // size of all files of a project
+}
+
+// fileLimitsFromEnv overrides the default limits with MAX_FILE_SIZE and MAX_PROJECT_SIZE ("256Ki", "1Mi")
+func fileLimitsFromEnv(limits fileLimits) (fileLimits, error) {
+	for env, value := range map[string]*int64{
+		"MAX_FILE_SIZE":    &limits.File,
+		"MAX_PROJECT_SIZE": &limits.Total,
+	} {
+		if v := os.Getenv(env); v != "" {
+			q, err := resource.ParseQuantity(v)
+			if err != nil {
+				return limits, fmt.Errorf("invalid %s: %v", env, err)
+			}
+			*value = q.Value()
+		}
+	}
+	return limits, nil
+}
+
+// check returns an error when a file or all files exceed the limits
+func (l fileLimits) check(files map[string]string) error {
+	var total int64
+	for _, p := range sortedPaths(files) {
+		size := int64(len(files[p]))
+		if size > l.File {
+			return fmt.Errorf("%s has %d bytes, files may have at most %d bytes", p, size, l.File)
+		}
+		total += size
+	}
+	if total > l.Total {
+		return fmt.Errorf("the project files have %d bytes, a project may have at most %d bytes", total, l.Total)
+	}
+	return nil
+}
+
+// validateFilePath rejects paths that aren't a clean relative path inside the working directory
+func validateFilePath(p string) error {
+	switch {
+	case p == "":
+		return fmt.Errorf("path is empty")
+	case len(p) > maxPathLength:
+		return fmt.Errorf("path has more than %d characters", maxPathLength)
+	case strings.ContainsAny(p, "\\\x00"):
+		return fmt.Errorf("invalid path %q: backslashes and NUL are not allowed", p)
+	case path.IsAbs(p):
+		return fmt.Errorf("invalid path %q: must be relative", p)
+	case path.Clean(p) != p:
+		return fmt.Errorf("invalid path %q: must be clean, like %q", p, path.Clean(p))
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." || elem == "." {
+			return fmt.Errorf("invalid path %q: must not leave the working directory", p)
+		}
+	}
+	if p == varsFile {
+		return fmt.Errorf("invalid path %q: holds the values of the variables, see SetVariables", p)
+	}
+	if dir, _, _ := strings.Cut(p, "/"); slices.Contains(reservedDirs, dir) {
+		return fmt.Errorf("invalid path %q: %s is used by the runner", p, dir)
+	}
+	return nil
+}
+
+// fileKey returns the ConfigMap key of a file other than projectFiles. Keys can't contain slashes,
+// the path is encoded, which never yields a dot like the keys of projectFiles.
+func fileKey(p string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(p))
+}
+
+// configMapKey returns the ConfigMap key of a file, projectFiles keep their name
+func configMapKey(p string) string {
+	if slices.Contains(projectFiles, p) {
+		return p
+	}
+	return fileKey(p)
+}
+
+// filePath decodes a key returned by fileKey
+func filePath(key string) (string, bool) {
+	p, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil || validateFilePath(string(p)) != nil {
+		return "", false
+	}
+	return string(p), true
+}
+
+// sortedPaths returns the paths of a set of files, projectFiles first in their order, then the others sorted
+func sortedPaths(files map[string]string) []string {
+	var paths, others []string
+	for _, p := range projectFiles {
+		if _, ok := files[p]; ok {
+			paths = append(paths, p)
+		}
+	}
+	for p := range files {
+		if !slices.Contains(projectFiles, p) {
+			others = append(others, p)
+		}
+	}
+	sort.Strings(others)
+	return append(paths, others...)
+}
+
+// encodeFiles returns the ConfigMap data holding a set of files, projectFiles keep their name as key
+func encodeFiles(files map[string]string) map[string]string {
+	data := make(map[string]string, len(files))
+	for p, content := range files {
+		data[configMapKey(p)] = content
+	}
+	return data
+}
+
+// decodeFiles returns the files of ConfigMap data written by encodeFiles, other keys are skipped
+func decodeFiles(data map[string]string) map[string]string {
+	files := make(map[string]string)
+	for key, content := range data {
+		if slices.Contains(projectFiles, key) {
+			files[key] = content
+			continue
+		}
+		if p, ok := filePath(key); ok {
+			files[p] = content
+		}
+	}
+	return files
+}
+
+// checkFile parses the configuration files terraform reads, other files are stored as they are
+func checkFile(p, content string) hcl.Diagnostics {
+	switch {
+	case strings.HasSuffix(p, ".tf"), strings.HasSuffix(p, ".tfvars"):
+		return utils.CheckHCL(p, []byte(content))
+	}
+	return nil
+}
+
+// PutFile creates or replaces a file of the project. Paths may contain directories, like modules/vpc/main.tf.
+func (s *ExecutorService) PutFile(ctx context.Context, req *pb.PutFileRequest) (*pb.PutFileResponse, error) {
+	if err := validateFilePath(req.Path); err != nil {
+		return &pb.PutFileResponse{Success: false, Error: err.Error()}, nil
+	}
+	if !utf8.ValidString(req.Content) {
+		return &pb.PutFileResponse{Success: false, Error: fmt.Sprintf("%s is not valid UTF-8", req.Path)}, nil
+	}
+	if diags := checkFile(req.Path, req.Content); diags.HasErrors() {
+		return &pb.PutFileResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid %s: %v", req.Path, diags.Error()),
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
+	}
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.PutFileResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	var created bool
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "PutFile"}, func() error {
+		files, err := s.store.List(ctx, req.UserId, req.Project)
+		if err != nil {
+			return err
+		}
+		prev, err := s.store.Get(ctx, req.UserId, req.Project, req.Path)
+		if err != nil {
+			return err
+		}
+		created = !prev.Exists
+		files[req.Path] = req.Content
+		if err := s.fileLimits.check(files); err != nil {
+			return err
+		}
+		return s.store.Put(ctx, req.UserId, req.Project, req.Path, prev, req.Content)
+	})
+	if err != nil {
+		return &pb.PutFileResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	return &pb.PutFileResponse{Success: true, Created: created, Revision: revision}, nil
+}
+
+// GetFile gets a file of the project.
+func (s *ExecutorService) GetFile(ctx context.Context, req *pb.GetFileRequest) (*pb.GetFileResponse, error) {
+	if err := validateFilePath(req.Path); err != nil {
+		return &pb.GetFileResponse{Success: false, Error: err.Error()}, nil
+	}
+	files, err := s.store.List(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetFileResponse{Success: false, Error: err.Error()}, nil
+	}
+	content, ok := files[req.Path]
+	if !ok {
+		return &pb.GetFileResponse{Success: false, Error: fmt.Sprintf("file %s not found", req.Path)}, nil
+	}
+	revision, err := s.latestRevision(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetFileResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.GetFileResponse{
+		Success: true,
+		File: &pb.ProjectFile{
+			Path:      req.Path,
+			Size:      int64(len(content)),
+			ConfigMap: s.store.ConfigMap(req.Project, req.Path),
+		},
+		Content:  content,
+		Revision: revision,
+	}, nil
+}
+
+// ListFiles lists the files of the project.
+func (s *ExecutorService) ListFiles(ctx context.Context, req *pb.ListFilesRequest) (*pb.ListFilesResponse, error) {
+	files, err := s.store.List(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.ListFilesResponse{Success: false, Error: err.Error()}, nil
+	}
+	revision, err := s.latestRevision(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.ListFilesResponse{Success: false, Error: err.Error()}, nil
+	}
+	resp := &pb.ListFilesResponse{Success: true, Revision: revision}
+	for _, p := range sortedPaths(files) {
+		size := int64(len(files[p]))
+		resp.Files = append(resp.Files, &pb.ProjectFile{
+			Path:      p,
+			Size:      size,
+			ConfigMap: s.store.ConfigMap(req.Project, p),
+		})
+		resp.TotalSize += size
+	}
+	return resp, nil
+}
+
+// DeleteFile deletes a file of the project.
+func (s *ExecutorService) DeleteFile(ctx context.Context, req *pb.DeleteFileRequest) (*pb.DeleteFileResponse, error) {
+	if err := validateFilePath(req.Path); err != nil {
+		return &pb.DeleteFileResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	var found bool
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "DeleteFile"}, func() error {
+		files, err := s.store.List(ctx, req.UserId, req.Project)
+		if err != nil {
+			return err
+		}
+		if _, found = files[req.Path]; !found {
+			return nil
+		}
+		return s.store.Delete(ctx, req.UserId, req.Project, req.Path)
+	})
+	if err != nil {
+		return &pb.DeleteFileResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	if !found {
+		return &pb.DeleteFileResponse{Success: false, Error: fmt.Sprintf("file %s not found", req.Path)}, nil
+	}
+	return &pb.DeleteFileResponse{Success: true, Revision: revision}, nil
+}
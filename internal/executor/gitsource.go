@@ -0,0 +1,522 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// gitTimeout bounds the fetch and checkout of a sync
+const gitTimeout = 2 * time.Minute
+
+var (
+	// scpURLRegexp matches the scp-like syntax of ssh URLs, like git@github.com:org/repo.git
+	scpURLRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:.+$`)
+	// gitRefRegexp matches branch, tag and commit names, a leading dash would be taken as an option
+	gitRefRegexp = regexp.MustCompile(`^[A-Za-z0-9._/][A-Za-z0-9._/-]*$`)
+)
+
+// gitBinding is the repository the files of a project are synced from
+type gitBinding struct {
+	URL          string
+	Ref          string
+	Subdirectory string
+	Commit       string
+	SyncedAt     time.Time
+}
+
+func (b *gitBinding) toProto(hasCredentials bool) *pb.GitBinding {
+	out := &pb.GitBinding{
+		Url:            b.URL,
+		Ref:            b.Ref,
+		Subdirectory:   b.Subdirectory,
+		Commit:         b.Commit,
+		HasCredentials: hasCredentials,
+	}
+	if !b.SyncedAt.IsZero() {
+		out.SyncedAt = timestamppb.New(b.SyncedAt)
+	}
+	return out
+}
+
+// gitConfigMapName returns the name of the ConfigMap holding the git binding of a project,
+// the Secret with its credentials has the same name
+func gitConfigMapName(project string) string {
+	return fmt.Sprintf("%s.%s", project, "git")
+}
+
+// gitFileURLsAllowed reports whether GIT_ALLOW_FILE_URLS allows file:// repositories. They would let
+// any user clone the repositories on the filesystem of the executor, only tests enable them.
+func gitFileURLsAllowed() bool {
+	allowed, _ := strconv.ParseBool(os.Getenv("GIT_ALLOW_FILE_URLS"))
+	return allowed
+}
+
+// gitAllowedProtocols returns the transports the fetch may use, git would run commands of other ones like ext::
+func gitAllowedProtocols() string {
+	if gitFileURLsAllowed() {
+		return "file:http:https:ssh"
+	}
+	return "http:https:ssh"
+}
+
+// validateGitURL accepts the transports allowed by the fetch
+func validateGitURL(raw string) error {
+	if strings.HasPrefix(raw, "file://") && !gitFileURLsAllowed() {
+		return fmt.Errorf("file:// repository URLs aren't allowed, expected https://, ssh:// or user@host:path")
+	}
+	switch {
+	case strings.HasPrefix(raw, "https://"), strings.HasPrefix(raw, "http://"), strings.HasPrefix(raw, "ssh://"), strings.HasPrefix(raw, "file://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid repository URL: %v", err)
+		}
+		if u.Scheme != "file" && u.Host == "" {
+			return fmt.Errorf("invalid repository URL %q, the host is missing", raw)
+		}
+		return nil
+	case scpURLRegexp.MatchString(raw):
+		return nil
+	}
+	return fmt.Errorf("unsupported repository URL %q, expected https://, ssh://, user@host:path or file://", raw)
+}
+
+// sshGitURL reports whether a repository is fetched over ssh
+func sshGitURL(raw string) bool {
+	return strings.HasPrefix(raw, "ssh://") || scpURLRegexp.MatchString(raw)
+}
+
+// getGitBinding returns the git binding of a project, nil if it isn't bound
+func (s *ExecutorService) getGitBinding(ctx context.Context, namespace, project string) (*gitBinding, error) {
+	cm, err := s.K8sClient.GetConfigMap(ctx, namespace, gitConfigMapName(project))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get git binding: %v", err)
+	}
+	b := &gitBinding{
+		URL:          cm.Data["url"],
+		Ref:          cm.Data["ref"],
+		Subdirectory: cm.Data["subdirectory"],
+		Commit:       cm.Data["commit"],
+	}
+	if syncedAt := cm.Data["synced_at"]; syncedAt != "" {
+		b.SyncedAt, _ = time.Parse(time.RFC3339, syncedAt)
+	}
+	return b, nil
+}
+
+// saveGitBinding creates or replaces the git binding of a project
+func (s *ExecutorService) saveGitBinding(ctx context.Context, namespace, project string, b *gitBinding) error {
+	data := map[string]string{
+		"url":          b.URL,
+		"ref":          b.Ref,
+		"subdirectory": b.Subdirectory,
+		"commit":       b.Commit,
+	}
+	if !b.SyncedAt.IsZero() {
+		data["synced_at"] = b.SyncedAt.Format(time.RFC3339)
+	}
+	cm, err := s.K8sClient.GetConfigMap(ctx, namespace, gitConfigMapName(project))
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap: %w", err)
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: gitConfigMapName(project)},
+			Data:       data,
+		}
+		if err := s.K8sClient.CreateConfigMap(ctx, namespace, cm); err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		return nil
+	}
+	cm.Data = data
+	if err := s.K8sClient.UpdateConfigMap(ctx, namespace, cm); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// getGitCredentials returns the credentials of the repository of a project, nil if it has none
+func (s *ExecutorService) getGitCredentials(ctx context.Context, namespace, project string) (map[string][]byte, error) {
+	secret, err := s.K8sClient.GetSecret(ctx, namespace, gitConfigMapName(project))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get git credentials: %v", err)
+	}
+	return secret.Data, nil
+}
+
+// saveGitCredentials replaces the credentials of the repository of a project
+func (s *ExecutorService) saveGitCredentials(ctx context.Context, namespace, project string, creds *pb.GitCredentials) error {
+	data := map[string][]byte{}
+	for key, value := range map[string]string{
+		"username":       creds.Username,
+		"password":       creds.Password,
+		"ssh-privatekey": creds.SshPrivateKey,
+		"known_hosts":    creds.KnownHosts,
+	} {
+		if value != "" {
+			data[key] = []byte(value)
+		}
+	}
+	secret, err := s.K8sClient.GetSecret(ctx, namespace, gitConfigMapName(project))
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get Secret: %w", err)
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: gitConfigMapName(project)},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       data,
+		}
+		if err := s.K8sClient.CreateSecret(ctx, namespace, secret); err != nil {
+			return fmt.Errorf("failed to create Secret: %w", err)
+		}
+		return nil
+	}
+	secret.Data = data
+	if err := s.K8sClient.UpdateSecret(ctx, namespace, secret); err != nil {
+		return fmt.Errorf("failed to update Secret: %w", err)
+	}
+	return nil
+}
+
+// clearGitBinding removes the git binding of a project and its credentials, the files stay
+func (s *ExecutorService) clearGitBinding(ctx context.Context, namespace, project string) error {
+	if err := s.K8sClient.DeleteConfigMap(ctx, namespace, gitConfigMapName(project)); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete git binding: %v", err)
+	}
+	if err := s.K8sClient.DeleteSecret(ctx, namespace, gitConfigMapName(project)); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete git credentials: %v", err)
+	}
+	return nil
+}
+
+// gitCommand runs git commands in a directory without the configuration of the host
+type gitCommand struct {
+	dir string
+	env []string
+}
+
+// newGitCommand prepares the environment of a fetch of a repository in a temporary directory, which
+// holds the credentials as long as the fetch runs
+func newGitCommand(tmp, repoURL string, creds map[string][]byte) (*gitCommand, error) {
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + tmp,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ALLOW_PROTOCOL=" + gitAllowedProtocols(),
+	}
+	if password := string(creds["password"]); password != "" {
+		// the header keeps the credentials out of the URL, which shows up in errors. It is scoped to
+		// the repository, git would send it to the host of a redirect otherwise.
+		auth := base64.StdEncoding.EncodeToString([]byte(string(creds["username"]) + ":" + password))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http."+repoURL+".extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth,
+		)
+	}
+	if !sshGitURL(repoURL) {
+		return &gitCommand{dir: tmp, env: env}, nil
+	}
+
+	// the host key must be known, the first connection could be intercepted otherwise
+	knownHosts := creds["known_hosts"]
+	if len(knownHosts) == 0 {
+		return nil, fmt.Errorf("the known_hosts of the ssh server are required, set them in the credentials of BindGit")
+	}
+	knownHostsFile := filepath.Join(tmp, "known_hosts")
+	if err := os.WriteFile(knownHostsFile, knownHosts, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write known_hosts: %v", err)
+	}
+	ssh := fmt.Sprintf("ssh -o BatchMode=yes -o UserKnownHostsFile=%s -o StrictHostKeyChecking=yes", knownHostsFile)
+	if key := creds["ssh-privatekey"]; len(key) > 0 {
+		keyFile := filepath.Join(tmp, "id_git")
+		if !bytes.HasSuffix(key, []byte("\n")) {
+			key = append(key, '\n')
+		}
+		if err := os.WriteFile(keyFile, key, 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write ssh key: %v", err)
+		}
+		ssh += fmt.Sprintf(" -i %s -o IdentitiesOnly=yes", keyFile)
+	}
+	env = append(env, "GIT_SSH_COMMAND="+ssh)
+	return &gitCommand{dir: tmp, env: env}, nil
+}
+
+// run runs a git command and returns its trimmed output
+func (g *gitCommand) run(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = g.env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("git %s timed out", args[0])
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], strings.ReplaceAll(strings.TrimSpace(stderr.String()), "\n", " "))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// fetchGit checks out the bound ref of a repository and returns the files of its subdirectory with the commit
+func fetchGit(ctx context.Context, b *gitBinding, creds map[string][]byte, limits fileLimits) (map[string]string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	tmp, err := os.MkdirTemp("", "git-sync-")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(tmp)
+	git, err := newGitCommand(tmp, b.URL, creds)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if _, err := git.run(ctx, "init", "-q", "repo"); err != nil {
+		return nil, "", err
+	}
+	git.dir = filepath.Join(tmp, "repo")
+	ref := b.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	// only the commit of the ref is fetched, a commit SHA needs a server allowing to fetch it
+	if _, err := git.run(ctx, "fetch", "-q", "--depth", "1", "--", b.URL, ref); err != nil {
+		return nil, "", err
+	}
+	commit, err := git.run(ctx, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := git.run(ctx, "-c", "advice.detachedHead=false", "checkout", "-q", commit); err != nil {
+		return nil, "", err
+	}
+
+	files, err := readCheckout(git.dir, b.Subdirectory, limits)
+	if err != nil {
+		return nil, "", err
+	}
+	return files, commit, nil
+}
+
+// readCheckout returns the files of a directory of a checkout. Like the entries of an archive,
+// only regular files are accepted, links are rejected.
+func readCheckout(repo, subdirectory string, limits fileLimits) (map[string]string, error) {
+	root := filepath.Join(repo, filepath.FromSlash(subdirectory))
+	// a link in the subdirectory would lead outside of the checkout
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("subdirectory %s not found in the repository", subdirectory)
+		}
+		return nil, err
+	}
+	base, err := filepath.EvalSymlinks(repo)
+	if err != nil {
+		return nil, err
+	}
+	if resolved != filepath.Join(base, filepath.FromSlash(subdirectory)) {
+		return nil, fmt.Errorf("subdirectory %s is a link, links are not allowed", subdirectory)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("subdirectory %s is not a directory", subdirectory)
+	}
+
+	entries := &archiveEntries{limits: limits, files: make(map[string]string)}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case rel == ".":
+			return nil
+		case d.IsDir() && d.Name() == ".git":
+			return fs.SkipDir
+		case d.Type()&fs.ModeSymlink != 0:
+			return fmt.Errorf("%s is a link, links are not allowed", rel)
+		case d.IsDir():
+			return entries.dir(rel)
+		case !d.Type().IsRegular():
+			return fmt.Errorf("%s is not a regular file", rel)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return entries.add(rel, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries.files, nil
+}
+
+// BindGit binds the project to a git repository. The files aren't changed until SyncFromGit,
+// from then on they can only be changed by syncing.
+func (s *ExecutorService) BindGit(ctx context.Context, req *pb.BindGitRequest) (*pb.BindGitResponse, error) {
+	if err := validateGitURL(req.Url); err != nil {
+		return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+	}
+	if req.Ref != "" && (!gitRefRegexp.MatchString(req.Ref) || strings.Contains(req.Ref, "..")) {
+		return &pb.BindGitResponse{Success: false, Error: fmt.Sprintf("invalid ref %q", req.Ref)}, nil
+	}
+	subdirectory := strings.Trim(req.Subdirectory, "/")
+	if subdirectory == "." {
+		subdirectory = ""
+	}
+	if subdirectory != "" {
+		if err := validateFilePath(subdirectory); err != nil {
+			return &pb.BindGitResponse{Success: false, Error: fmt.Sprintf("invalid subdirectory: %v", err)}, nil
+		}
+	}
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	binding := &gitBinding{URL: req.Url, Ref: req.Ref, Subdirectory: subdirectory}
+	existing, err := s.getGitBinding(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+	}
+	// the last sync still applies to the same ref, otherwise runs wait for the next one
+	if existing != nil && existing.URL == binding.URL && existing.Ref == binding.Ref && existing.Subdirectory == binding.Subdirectory {
+		binding.Commit, binding.SyncedAt = existing.Commit, existing.SyncedAt
+	}
+
+	if sshGitURL(req.Url) {
+		// a sync fails without the host key of the server, the binding would be of no use
+		knownHosts := req.GetCredentials().GetKnownHosts()
+		if req.Credentials == nil {
+			creds, err := s.getGitCredentials(ctx, req.UserId, req.Project)
+			if err != nil {
+				return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+			}
+			knownHosts = string(creds["known_hosts"])
+		}
+		if knownHosts == "" {
+			return &pb.BindGitResponse{Success: false, Error: "ssh repositories require the known_hosts of the server in the credentials"}, nil
+		}
+	}
+	if req.Credentials != nil {
+		if err := s.saveGitCredentials(ctx, req.UserId, req.Project, req.Credentials); err != nil {
+			return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+		}
+	}
+	if err := s.saveGitBinding(ctx, req.UserId, req.Project, binding); err != nil {
+		return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+	}
+	creds, err := s.getGitCredentials(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.BindGitResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.BindGitResponse{Success: true, Binding: binding.toProto(len(creds) > 0)}, nil
+}
+
+// GetGitBinding returns the git repository of the project, the binding is unset if it isn't bound.
+func (s *ExecutorService) GetGitBinding(ctx context.Context, req *pb.GetGitBindingRequest) (*pb.GetGitBindingResponse, error) {
+	binding, err := s.getGitBinding(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetGitBindingResponse{Success: false, Error: err.Error()}, nil
+	}
+	if binding == nil {
+		return &pb.GetGitBindingResponse{Success: true}, nil
+	}
+	creds, err := s.getGitCredentials(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetGitBindingResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.GetGitBindingResponse{Success: true, Binding: binding.toProto(len(creds) > 0)}, nil
+}
+
+// UnbindGit unbinds the project from its git repository and deletes the credentials. The files
+// of the last sync stay and can be changed again.
+func (s *ExecutorService) UnbindGit(ctx context.Context, req *pb.UnbindGitRequest) (*pb.UnbindGitResponse, error) {
+	if err := s.clearGitBinding(ctx, req.UserId, req.Project); err != nil {
+		return &pb.UnbindGitResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.UnbindGitResponse{Success: true}, nil
+}
+
+// SyncFromGit replaces the files of the project with the files of the bound ref. All files are
+// validated before any file is written, the new files are recorded as one revision with the commit.
+func (s *ExecutorService) SyncFromGit(ctx context.Context, req *pb.SyncFromGitRequest) (*pb.SyncFromGitResponse, error) {
+	binding, err := s.getGitBinding(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.SyncFromGitResponse{Success: false, Error: err.Error()}, nil
+	}
+	if binding == nil {
+		return &pb.SyncFromGitResponse{Success: false, Error: fmt.Sprintf("project %s is not bound to a git repository", req.Project)}, nil
+	}
+	creds, err := s.getGitCredentials(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.SyncFromGitResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	files, commit, err := fetchGit(ctx, binding, creds, s.fileLimits)
+	if err != nil {
+		return &pb.SyncFromGitResponse{Success: false, Error: fmt.Sprintf("failed to sync from %s: %v", binding.URL, err)}, nil
+	}
+	var diags hcl.Diagnostics
+	for _, p := range sortedPaths(files) {
+		diags = append(diags, checkFile(p, files[p])...)
+	}
+	if diags.HasErrors() {
+		return &pb.SyncFromGitResponse{
+			Success:     false,
+			Error:       fmt.Sprintf("invalid files at commit %s: %v", commit, diags.Error()),
+			Commit:      commit,
+			Diagnostics: s.hclDiagnostics(req.Project, diags),
+		}, nil
+	}
+
+	c := change{RequestID: req.RequestId, Author: req.Author, Action: "SyncFromGit", Commit: commit}
+	revision, err := s.mutateRevision(ctx, req.UserId, req.Project, req.ExpectedRevision, c, func() error {
+		if err := s.replaceFiles(ctx, req.UserId, req.Project, files); err != nil {
+			return err
+		}
+		// runs execute the commit of the binding, it follows the files
+		binding.Commit, binding.SyncedAt = commit, time.Now().UTC()
+		return s.saveGitBinding(ctx, req.UserId, req.Project, binding)
+	})
+	if err != nil {
+		return &pb.SyncFromGitResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+
+	resp := &pb.SyncFromGitResponse{Success: true, Commit: commit, Revision: revision}
+	resp.Files, resp.TotalSize = s.listedFiles(req.Project, files)
+	return resp, nil
+}
@@ -1,9 +1,11 @@
 package executor
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	pb "terraform-executor/api/proto"
 	"time"
@@ -13,19 +15,37 @@ import (
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/utils/ptr"
 )
 
-// Helper function to create Terraform job
-func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name, namespace, project string, runType string, args []string) (*batchv1.Job, error) {
-	// get env vars from secret
-	secretName := fmt.Sprintf("%s.%s", project, "env")
+// awsCLIImage is used by the helper containers that move plan artifacts to and from S3
+const awsCLIImage = "amazon/aws-cli:latest"
+
+// Helper function to create the Terraform job of a run with the runtime recorded on the run.
+// A plan run uploads the saved plan to S3 and an apply run with a plan ID applies it.
+func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, r *run) (*batchv1.Job, error) {
+	name, namespace, project, runType, args, planID := r.JobName, r.UserID, r.Project, r.Type, r.args(), r.PlanID
+
+	// reference the secret env vars by key, their values never appear in the Job and Pod specs
+	secretName := envSecretName(project)
 	envVars := []corev1.EnvVar{}
 	if secret, err := s.K8sClient.GetSecret(ctx, namespace, secretName); err == nil {
-		for key, value := range secret.Data {
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
 			envVars = append(envVars, corev1.EnvVar{
-				Name:  key,
-				Value: string(value),
+				Name: key,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+						Key:                  key,
+						Optional:             ptr.To(true), // cleared before the pod started
+					},
+				},
 			})
 		}
 		// Ignore error if secret does not exist
@@ -41,6 +61,13 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 			Value: "/root/.terraform.d/plugin-cache",
 		},
 	)
+	if r.offline() {
+		// the lock file of an offline run is thrown away, use cached providers without verifying their checksums online
+		envVars = append(envVars, corev1.EnvVar{
+			Name:  "TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE",
+			Value: "true",
+		})
+	}
 
 	// dynamic volume mounts
 	volumeMounts := []corev1.VolumeMount{}
@@ -52,17 +79,28 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 		Name:      "aws-creds",
 		MountPath: "/root/.aws",
 	}
-	volumeMounts = append(volumeMounts, pluginsVolumeMount, awsCredsMount)
-	for _, vol := range []string{"main.tf", "versions.tf", "variables.tf"} {
-		// add volume mounts for main.tf, versions.tf, and variables.tf if config maps exist
-		if _, err := s.K8sClient.GetConfigMap(ctx, namespace, fmt.Sprintf("%s.%s", project, vol)); err == nil {
-			volumeMounts = append(volumeMounts, corev1.VolumeMount{
-				Name:      strings.TrimSuffix(vol, ".tf"),
-				MountPath: fmt.Sprintf("/root/%s", vol),
-				SubPath:   vol,
-			})
-		}
+	workspaceMount := corev1.VolumeMount{
+		Name:      "workspace",
+		MountPath: "/workspace",
+	}
+	// the values of the variables are linked into the working directory, see SetVariables
+	variablesMount := corev1.VolumeMount{
+		Name:      "variables",
+		MountPath: "/variables",
+		ReadOnly:  true,
+	}
+	volumeMounts = append(volumeMounts, pluginsVolumeMount, awsCredsMount, workspaceMount, variablesMount)
+
+	// the run executes the files of the revision it was started with
+	rev, err := s.revisionRecord(ctx, namespace, project, r.Revision)
+	if err != nil {
+		return nil, err
+	}
+	config := &runnerPod{}
+	if err := s.store.Mount(ctx, namespace, project, rev, config); err != nil {
+		return nil, fmt.Errorf("failed to mount revision %d: %v", rev.Number, err)
 	}
+	volumeMounts = append(volumeMounts, config.Mounts...)
 
 	// dynamic volumes
 	volumes := []corev1.Volume{
@@ -80,6 +118,7 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 			VolumeSource: corev1.VolumeSource{
 				Secret: &corev1.SecretVolumeSource{
 					SecretName: "aws-profile",
+					Optional:   ptr.To(r.offline() && !s.store.Credentials()), // offline runs don't need credentials, unless to fetch the files
 					Items: []corev1.KeyToPath{
 						{
 							Key:  "credentials",
@@ -89,20 +128,83 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 				},
 			},
 		},
+		{
+			Name: "workspace",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: "variables",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: varsSecretName(project),
+					Optional:   ptr.To(true), // projects without variables have no values
+				},
+			},
+		},
 	}
 
-	for _, vol := range []string{"main.tf", "versions.tf", "variables.tf"} {
-		if _, err := s.K8sClient.GetConfigMap(ctx, namespace, fmt.Sprintf("%s.%s", project, vol)); err == nil {
-			volumes = append(volumes, corev1.Volume{
-				Name: strings.TrimSuffix(vol, ".tf"),
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: fmt.Sprintf("%s.%s", project, vol),
-						},
-					},
+	volumes = append(volumes, config.Volumes...)
+
+	// env for the helper containers talking to the state bucket with the user's credentials
+	s3Env := []corev1.EnvVar{
+		{Name: "AWS_PROFILE", Value: "tfstate"},
+		{Name: "AWS_REGION", Value: s.Region},
+		{Name: "PLAN_URL", Value: fmt.Sprintf("s3://%s/%s/", s.Bucket, planPrefix(namespace, project, planID))},
+	}
+	helperMounts := []corev1.VolumeMount{awsCredsMount, workspaceMount}
+
+	bin := r.binary()
+	initArgs := "-no-color -input=false"
+	if r.offline() {
+		initArgs += " -backend=false"
+	}
+	setup := fmt.Sprintf("{ [ ! -f /variables/%[1]s ] || ln -sf /variables/%[1]s %[1]s; } && ", varsFile)
+	if config.Setup != "" {
+		setup = config.Setup + " && " + setup
+	}
+	script := fmt.Sprintf("%[4]s%[1]s init %[3]s && %[1]s %[2]s", bin, strings.Join(args, " "), initArgs, setup)
+	initContainers := config.InitContainers
+	sidecars := []corev1.Container{}
+	if planID != "" {
+		switch runType {
+		case "plan":
+			// Save the plan, its JSON and text rendering, the detailed exit code and the provider lock file, then signal the uploader.
+			// The detailed exit code 2 means the plan has changes and is no failure.
+			script = fmt.Sprintf(
+				"(mkdir -p /workspace/plan && { %[1]s -out=/workspace/plan/tfplan; rc=$?; echo $rc > /workspace/plan/detailed-exitcode; [ $rc -ne 1 ]; } && "+
+					"%[2]s show -json /workspace/plan/tfplan > /workspace/plan/plan.json && %[2]s show -no-color /workspace/plan/tfplan > /workspace/plan/plan.txt && "+
+					"cp .terraform.lock.hcl /workspace/plan/); "+
+					"rc=$?; echo $rc > /workspace/exitcode; exit $rc",
+				script, bin,
+			)
+			sidecars = append(sidecars, corev1.Container{
+				Name:    "uploader",
+				Image:   awsCLIImage,
+				Command: []string{"/bin/sh", "-c"},
+				Args: []string{
+					"until [ -f /workspace/exitcode ]; do sleep 1; done; " +
+						"[ \"$(cat /workspace/exitcode)\" = \"0\" ] || exit 0; " +
+						"aws s3 cp --recursive --no-progress /workspace/plan/ \"$PLAN_URL\"",
 				},
+				Env:          s3Env,
+				VolumeMounts: helperMounts,
+			})
+		case "apply":
+			// Restore the saved plan and the provider lock file it was created with
+			initContainers = append(initContainers, corev1.Container{
+				Name:         "init",
+				Image:        awsCLIImage,
+				Command:      []string{"/bin/sh", "-c"},
+				Args:         []string{"aws s3 cp --recursive --no-progress \"$PLAN_URL\" /workspace/plan/"},
+				Env:          s3Env,
+				VolumeMounts: helperMounts,
 			})
+			script = fmt.Sprintf(
+				"%[3]scp /workspace/plan/.terraform.lock.hcl . && %[1]s init -no-color -input=false && %[1]s %[2]s /workspace/plan/tfplan",
+				bin, strings.Join(args, " "), setup,
+			)
 		}
 	}
 
@@ -115,6 +217,7 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 				"user":    namespace,
 				"project": project,
 				"type":    runType,
+				"run-id":  r.ID,
 			},
 		},
 		Spec: batchv1.JobSpec{
@@ -128,22 +231,21 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 						"user":    namespace,
 						"project": project,
 						"type":    runType,
+						"run-id":  r.ID,
 					},
 				},
 				Spec: corev1.PodSpec{
-					RestartPolicy: corev1.RestartPolicyNever,
-					Containers: []corev1.Container{
+					RestartPolicy:  corev1.RestartPolicyNever,
+					InitContainers: initContainers,
+					Containers: append([]corev1.Container{
 						{
 							Name:       "runner",
 							WorkingDir: "/root",
-							Image:      "hashicorp/terraform:latest",
+							Image:      r.Image,
 							Command: []string{
 								"/bin/sh",
 								"-c",
-								fmt.Sprintf(
-									"terraform init -no-color -input=false && terraform %s",
-									strings.Join(args, " "),
-								),
+								script,
 							},
 							Env:          envVars,
 							VolumeMounts: volumeMounts,
@@ -158,7 +260,7 @@ func (s *ExecutorService) createTerraformJobTemplate(ctx context.Context, name,
 								},
 							},
 						},
-					},
+					}, sidecars...),
 					Volumes: volumes,
 				},
 			},
@@ -171,6 +273,12 @@ func (s *ExecutorService) getPodLogs(ctx context.Context, userId string, pod *co
 	// check if pod has init container
 	var allLogs []string
 
+	// Get config init container logs, it fetches the project files of a store in S3
+	configLogs, err := s.K8sClient.GetPodLogs(ctx, userId, pod.Name, "config")
+	if err == nil && strings.TrimSpace(configLogs) != "" {
+		allLogs = append(allLogs, fmt.Sprintf("Config container logs:\n%s", configLogs))
+	}
+
 	// Get init container logs
 	initLogs, err := s.K8sClient.GetPodLogs(ctx, userId, pod.Name, "init")
 	if err == nil && strings.TrimSpace(initLogs) != "" {
@@ -190,7 +298,9 @@ func (s *ExecutorService) getPodLogs(ctx context.Context, userId string, pod *co
 	return strings.Join(allLogs, "\n\n"), nil
 }
 
-func (s *ExecutorService) waitForJobAndGetLogs(ctx context.Context, userId, project, requestId, jobName string) (string, error) {
+func (s *ExecutorService) waitForJobAndGetLogs(ctx context.Context, r *run) (string, error) {
+	userId, jobName := r.UserID, r.JobName
+
 	var result struct {
 		logs string
 		err  error
@@ -205,25 +315,29 @@ func (s *ExecutorService) waitForJobAndGetLogs(ctx context.Context, userId, proj
 			return "", fmt.Errorf("failed to get initial job status: %v", err)
 		}
 	} else {
-		if job.Status.Failed > 0 {
-			pod, err := s.K8sClient.GetJobPod(ctx, userId, jobName)
-			if err != nil {
-				return "", fmt.Errorf("failed to get job pod: %v", err)
-			}
-			return s.getPodLogs(ctx, userId, pod)
-		}
-		if job.Status.Succeeded > 0 {
-			pod, err := s.K8sClient.GetJobPod(ctx, userId, jobName)
-			if err != nil {
-				return "", fmt.Errorf("failed to get job pod: %v", err)
-			}
-			return s.getPodLogs(ctx, userId, pod)
+		if job.Status.Failed > 0 || job.Status.Succeeded > 0 {
+			return s.completedPodLogs(ctx, userId, jobName)
 		}
 	}
 
-	err = s.streamPodLogsAndSendRPC(ctx, userId, project, requestId, jobName, *s.LogStream)
+	// The pod is watched while streaming its logs, a stuck pod cancels the stream
+	monitorCtx, cancelMonitor := context.WithCancel(ctx)
+	defer cancelMonitor()
+	failed := make(chan *podFailure, 1)
+	go s.monitorPod(monitorCtx, r, cancelMonitor, failed)
+
+	err = s.streamPodLogs(monitorCtx, r)
+	select {
+	case f := <-failed:
+		return "", f
+	default:
+	}
 	if err != nil {
-		return "", fmt.Errorf("streamPodLogsAndSendRPC failed to stream pod logs: %v", err)
+		return "", fmt.Errorf("streamPodLogs failed to stream pod logs: %v", err)
+	}
+	// The job may have been deleted by CancelRun while streaming
+	if _, err := s.K8sClient.GetJob(ctx, userId, jobName); k8serrors.IsNotFound(err) {
+		return "", fmt.Errorf("job %s was deleted", jobName)
 	}
 	watcher, err := s.K8sClient.WatchJob(ctx, userId, jobName)
 	if err != nil {
@@ -236,6 +350,10 @@ func (s *ExecutorService) waitForJobAndGetLogs(ctx context.Context, userId, proj
 	go func() {
 		defer close(done)
 		for event := range watcher.ResultChan() {
+			if event.Type == watch.Deleted {
+				result.err = fmt.Errorf("job %s was deleted", jobName)
+				return
+			}
 			job, ok := event.Object.(*batchv1.Job)
 			if !ok {
 				continue
@@ -245,17 +363,7 @@ func (s *ExecutorService) waitForJobAndGetLogs(ctx context.Context, userId, proj
 				fmt.Printf("Job %s completed with status: Failed=%d, Succeeded=%d\n",
 					jobName, job.Status.Failed, job.Status.Succeeded)
 
-				pod, err := s.K8sClient.GetJobPod(ctx, userId, jobName)
-				if err != nil {
-					result.err = fmt.Errorf("failed to get job pod: %v", err)
-					return
-				}
-				logs, err := s.getPodLogs(ctx, userId, pod)
-				if err != nil {
-					result.err = fmt.Errorf("failed to get pod logs: %v", err)
-					return
-				}
-				result.logs = logs
+				result.logs, result.err = s.completedPodLogs(ctx, userId, jobName)
 				return
 			}
 		}
@@ -265,74 +373,214 @@ func (s *ExecutorService) waitForJobAndGetLogs(ctx context.Context, userId, proj
 	select {
 	case <-done:
 		return result.logs, result.err
-	case <-time.After(15 * time.Minute):
-		return "", fmt.Errorf("job timed out after 15 minutes")
+	case f := <-failed:
+		return "", f
+	case <-time.After(s.podThresholds.Job):
+		return "", &podFailure{
+			Reason:  failureJobTimeout,
+			Message: fmt.Sprintf("job timed out after %s", s.podThresholds.Job),
+		}
 	}
 }
 
-func (s *ExecutorService) streamPodLogsAndSendRPC(ctx context.Context, userId, project, requestId string, jobName string, stream pb.Executor_StreamLogsServer) error {
-	// Set up polling mechanism for logs (every 1 second)
-	logTicker := time.NewTicker(1 * time.Second)
-	defer logTicker.Stop()
-	var lastLog string // Keeps track of previously sent logs to avoid duplication
+// completedPodLogs returns the logs of the pod of a completed job, or the failure of a pod killed
+// before terraform could report it
+func (s *ExecutorService) completedPodLogs(ctx context.Context, userId, jobName string) (string, error) {
+	pod, err := s.K8sClient.GetJobPod(ctx, userId, jobName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get job pod: %v", err)
+	}
+	logs, err := s.getPodLogs(ctx, userId, pod)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod logs: %v", err)
+	}
+	if f := s.terminalPodFailure(pod); f != nil {
+		return logs, f
+	}
+	return logs, nil
+}
 
-	completionChan := make(chan error, 1) // Channel to signal completion
+// sendLog publishes log text of a run and the event it was decoded from, if any, to the log subscribers
+func (s *ExecutorService) sendLog(r *run, text string, event *pb.RunEvent) {
+	s.logs.publish(&pb.LogStreamResponse{
+		LogLine:   text,
+		UserId:    r.UserID,
+		Project:   r.Project,
+		RequestId: r.RequestID,
+		RunId:     r.ID,
+		Event:     event,
+	})
+}
 
-	go func() {
-		failedAttempts := 0 // Counter for failed pod retrieval attempts
+// publishLogLine publishes a line of the runner output, decoding -json UI output into an event
+func (s *ExecutorService) publishLogLine(r *run, line string) {
+	if event := decodeUIEvent(line); event != nil {
+		s.sendLog(r, renderUIEvent(event)+"\n", event)
+		return
+	}
+	s.sendLog(r, line+"\n", nil)
+}
 
-		for {
+// streamPodLogs follows the logs of the init containers and the runner container of the run's pod
+// in sequence and publishes them line by line until the runner container terminated
+func (s *ExecutorService) streamPodLogs(ctx context.Context, r *run) error {
+	var pod *corev1.Pod
+	for failedAttempts := 0; pod == nil; {
+		var err error
+		pod, err = s.K8sClient.GetJobPod(ctx, r.UserID, r.JobName)
+		if err != nil {
+			log.Printf("Error getting pod: %v", err)
+			failedAttempts++
+			if failedAttempts >= 5 {
+				log.Print("failed to get pod 5 times. exiting")
+				return nil
+			}
 			select {
 			case <-ctx.Done():
-				// Stop streaming if context is cancelled
-				completionChan <- ctx.Err()
-				return
-			case <-logTicker.C:
-				podFresh, err := s.K8sClient.GetJobPod(ctx, userId, jobName)
-				if err != nil {
-					log.Printf("Error getting pod: %v", err)
-					failedAttempts++
-					if failedAttempts >= 5 {
-						log.Print("failed to get pod 5 times. exiting")
-						completionChan <- nil
-						return
-					}
-					continue // Corrected: Continue to the next tick, don't return
-				}
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+		}
+	}
+
+	containers := []string{}
+	for _, c := range pod.Spec.InitContainers {
+		containers = append(containers, c.Name)
+	}
+	containers = append(containers, "runner")
+	for _, container := range containers {
+		if err := s.followContainerLogs(ctx, r, pod.Name, container); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-				failedAttempts = 0 // Reset counter on successful retrieval
+// containerState returns the state of a container of a pod
+func containerState(pod *corev1.Pod, container string) *corev1.ContainerState {
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			if statuses[i].Name == container {
+				return &statuses[i].State
+			}
+		}
+	}
+	return nil
+}
 
-				if podFresh.Status.Phase == corev1.PodSucceeded || podFresh.Status.Phase == corev1.PodFailed {
-					log.Printf("pod completed, stopping ticker")
-					completionChan <- nil
-					return
-				}
+// logCursor tracks the timestamps of the log lines of a container published so far. A stream resumed
+// at the last timestamp replays the lines stamped with it, several lines may share a timestamp.
+type logCursor struct {
+	last   time.Time
+	atLast int // lines published stamped with last
+	skip   int // lines stamped with last the resumed stream replays
+}
 
-				if podFresh.Status.Phase != corev1.PodRunning {
-					// Pod isn't in a state where logs can be retrieved
-					fmt.Println("Pod is not in a running state. Will retry.")
-					continue
-				}
-				// Pod is running, now try to fetch logs
-				runnerLogs, err := s.getPodLogs(ctx, userId, podFresh)
-				if err != nil {
-					// Log the error and continue retrying
-					fmt.Printf("Error retrieving logs: %v\n", err)
-					continue // Retry after the next tick
-				}
-				// Check if there are new logs (by comparing with last sent logs)
-				if runnerLogs != lastLog {
-					// Extract the new portion of logs by removing the prefix that matches previous logs
-					logDiff := strings.TrimPrefix(runnerLogs, lastLog)
-					lastLog = runnerLogs // Update the last seen logs
-
-					if err := stream.Send(&pb.LogStreamResponse{LogLine: logDiff, UserId: userId, Project: project, RequestId: requestId}); err != nil {
-						log.Printf("Error sending logs via stream: %v\n", err)
-						continue
+// resume returns the time to resume the stream at, nil for the first stream
+func (c *logCursor) resume() *metav1.Time {
+	if c.last.IsZero() {
+		return nil
+	}
+	c.skip = c.atLast
+	return &metav1.Time{Time: c.last}
+}
+
+// publish reports whether a line stamped t wasn't published yet and counts it
+func (c *logCursor) publish(t time.Time) bool {
+	switch {
+	case t.Before(c.last):
+		return false
+	case t.Equal(c.last):
+		if c.skip > 0 {
+			c.skip--
+			return false
+		}
+		c.atLast++
+		return true
+	}
+	c.last, c.atLast, c.skip = t, 1, 0
+	return true
+}
+
+// followContainerLogs publishes the logs of a container until it terminated. A stream ending
+// while the container still runs is resumed at the timestamp of the last line published.
+func (s *ExecutorService) followContainerLogs(ctx context.Context, r *run, podName, container string) error {
+	var cursor logCursor
+	failedAttempts := 0
+	for {
+		pod, err := s.K8sClient.GetPod(ctx, r.UserID, podName)
+		if err != nil {
+			// the pod was deleted, e.g. by CancelRun
+			log.Printf("Error getting pod: %v", err)
+			return nil
+		}
+		state := containerState(pod, container)
+		if state == nil || state.Waiting != nil {
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				// the container never started, e.g. because an init container failed
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		terminated := state.Terminated != nil
+
+		stream, err := s.K8sClient.FollowPodLogs(ctx, r.UserID, podName, container, cursor.resume())
+		if err != nil {
+			failedAttempts++
+			if failedAttempts >= 5 {
+				return fmt.Errorf("failed to follow logs of container %s: %v", container, err)
+			}
+			log.Printf("Error following logs of container %s: %v", container, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		failedAttempts = 0
+
+		reader := bufio.NewReader(stream)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				ts, text, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+				t, perr := time.Parse(time.RFC3339Nano, ts)
+				switch {
+				case perr != nil:
+					// not prefixed with a timestamp, publish it as it is
+					s.publishLogLine(r, strings.TrimRight(line, "\r\n"))
+				case !cursor.publish(t):
+					// already published before the stream was resumed
+				default:
+					s.publishLogLine(r, text)
+					if container == "runner" {
+						s.observeRunLog(ctx, r, text)
 					}
 				}
 			}
+			if err != nil {
+				break
+			}
 		}
-	}()
-	return <-completionChan // Wait for completion signal
+		stream.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// the logs of a terminated container are complete, otherwise the stream was interrupted
+		if terminated {
+			return nil
+		}
+		if pod, err := s.K8sClient.GetPod(ctx, r.UserID, podName); err == nil {
+			if state := containerState(pod, container); state != nil && state.Terminated != nil {
+				continue // read the remaining lines once more, already published lines are skipped
+			}
+		}
+		log.Printf("Log stream of container %s interrupted, resuming", container)
+	}
 }
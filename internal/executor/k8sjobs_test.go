@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogCursorEqualTimestamps(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Millisecond)
+
+	var c logCursor
+	if c.resume() != nil {
+		t.Fatal("first stream must not resume")
+	}
+	// lines sharing a timestamp within one stream are all published
+	for i, ts := range []time.Time{t0, t0, t1, t1, t1} {
+		if !c.publish(ts) {
+			t.Fatalf("line %d of the first stream was dropped", i)
+		}
+	}
+
+	// the resumed stream replays the lines from the last timestamp on
+	since := c.resume()
+	if since == nil || !since.Time.Equal(t1) {
+		t.Fatalf("resumed at %v, want %v", since, t1)
+	}
+	for i, ts := range []time.Time{t0, t1, t1, t1} {
+		if c.publish(ts) {
+			t.Fatalf("replayed line %d was published again", i)
+		}
+	}
+	for i, ts := range []time.Time{t1, t1, t1.Add(time.Millisecond)} {
+		if !c.publish(ts) {
+			t.Fatalf("new line %d of the resumed stream was dropped", i)
+		}
+	}
+}
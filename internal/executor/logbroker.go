@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"sync"
+	"sync/atomic"
+	pb "terraform-executor/api/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultLogBuffer is the number of messages buffered per subscriber unless it asks for another size
+	defaultLogBuffer = 256
+	// maxLogBuffer bounds the buffer a subscriber may ask for
+	maxLogBuffer = 4096
+)
+
+// logBroker fans out the logs of runs to the StreamLogs subscribers.
+// Publishing never blocks, runs work the same without subscribers.
+type logBroker struct {
+	mu   sync.Mutex
+	subs map[*logSubscriber]struct{}
+}
+
+// logSubscriber is a StreamLogs client with its filter and bounded buffer
+type logSubscriber struct {
+	userId, project, requestId, runId string
+	policy                            pb.LogStreamRequest_SlowConsumerPolicy
+
+	ch           chan *pb.LogStreamResponse
+	dropped      atomic.Uint64 // messages dropped since the last delivered one
+	disconnected chan struct{} // closed when the DISCONNECT policy removed the subscriber
+}
+
+func newLogBroker() *logBroker {
+	return &logBroker{subs: make(map[*logSubscriber]struct{})}
+}
+
+// subscribe registers a subscriber receiving the messages matching the request filters
+func (b *logBroker) subscribe(req *pb.LogStreamRequest) *logSubscriber {
+	size := int(req.BufferSize)
+	if size <= 0 {
+		size = defaultLogBuffer
+	}
+	if size > maxLogBuffer {
+		size = maxLogBuffer
+	}
+	sub := &logSubscriber{
+		userId:       req.UserId,
+		project:      req.Project,
+		requestId:    req.RequestId,
+		runId:        req.RunId,
+		policy:       req.SlowConsumerPolicy,
+		ch:           make(chan *pb.LogStreamResponse, size),
+		disconnected: make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// unsubscribe removes a subscriber
+func (b *logBroker) unsubscribe(sub *logSubscriber) {
+	b.mu.Lock()
+	delete(b.subs, sub)
+	b.mu.Unlock()
+}
+
+// matches reports whether a message passes the filters of the subscriber, empty filters match everything
+func (sub *logSubscriber) matches(msg *pb.LogStreamResponse) bool {
+	return (sub.userId == "" || sub.userId == msg.UserId) &&
+		(sub.project == "" || sub.project == msg.Project) &&
+		(sub.requestId == "" || sub.requestId == msg.RequestId) &&
+		(sub.runId == "" || sub.runId == msg.RunId)
+}
+
+// publish delivers a message to the matching subscribers, applying their policy when their buffer is full
+func (b *logBroker) publish(msg *pb.LogStreamResponse) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !sub.matches(msg) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+			continue
+		default:
+		}
+
+		switch sub.policy {
+		case pb.LogStreamRequest_DROP_NEWEST:
+			sub.dropped.Add(1)
+		case pb.LogStreamRequest_DISCONNECT:
+			delete(b.subs, sub)
+			close(sub.disconnected)
+		default:
+			// DROP_OLDEST, the subscriber may have drained the buffer in between
+			select {
+			case <-sub.ch:
+				sub.dropped.Add(1)
+			default:
+			}
+			select {
+			case sub.ch <- msg:
+			default:
+				sub.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// next waits for the next message of the subscriber. It reports how many messages
+// were dropped before it and fails if the subscriber was disconnected for being too slow.
+func (sub *logSubscriber) next(done <-chan struct{}) (*pb.LogStreamResponse, error) {
+	select {
+	case <-done:
+		return nil, nil
+	case <-sub.disconnected:
+		return nil, status.Error(codes.ResourceExhausted, "log stream consumer too slow, buffer overflowed")
+	case msg := <-sub.ch:
+		if n := sub.dropped.Swap(0); n > 0 {
+			// messages are shared between subscribers
+			msg = proto.Clone(msg).(*pb.LogStreamResponse)
+			msg.Dropped = n
+		}
+		return msg, nil
+	}
+}
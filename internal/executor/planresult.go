@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	pb "terraform-executor/api/proto"
+
+	"terraform-executor/internal/awsclient"
+)
+
+// tfPlan is the subset of the `terraform show -json` plan representation the executor reads
+type tfPlan struct {
+	ResourceChanges []tfResourceChange  `json:"resource_changes"`
+	OutputChanges   map[string]tfChange `json:"output_changes"`
+}
+
+type tfResourceChange struct {
+	Address      string   `json:"address"`
+	Mode         string   `json:"mode"`
+	Type         string   `json:"type"`
+	Name         string   `json:"name"`
+	ProviderName string   `json:"provider_name"`
+	Change       tfChange `json:"change"`
+	ActionReason string   `json:"action_reason"`
+}
+
+type tfChange struct {
+	Actions         []string        `json:"actions"`
+	Before          json.RawMessage `json:"before"`
+	After           json.RawMessage `json:"after"`
+	AfterUnknown    json.RawMessage `json:"after_unknown"`
+	BeforeSensitive json.RawMessage `json:"before_sensitive"`
+	AfterSensitive  json.RawMessage `json:"after_sensitive"`
+	ReplacePaths    [][]any         `json:"replace_paths"`
+}
+
+// planResult is the structured outcome of a plan
+type planResult struct {
+	Text            string
+	HasChanges      bool
+	ToAdd           int32
+	ToChange        int32
+	ToDestroy       int32
+	ResourceChanges []*pb.ResourceChange
+	OutputChanges   []*pb.OutputChange
+}
+
+// parsePlanJSON converts the JSON rendering of a plan, counting changes like terraform's plan summary
+func parsePlanJSON(content []byte) (*planResult, error) {
+	var plan tfPlan
+	if err := json.Unmarshal(content, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan JSON: %v", err)
+	}
+
+	result := &planResult{}
+	for _, rc := range plan.ResourceChanges {
+		actions := rc.Change.Actions
+		if rc.Mode == "managed" {
+			switch strings.Join(actions, ",") {
+			case "create":
+				result.ToAdd++
+			case "update":
+				result.ToChange++
+			case "delete":
+				result.ToDestroy++
+			case "delete,create", "create,delete":
+				result.ToAdd++
+				result.ToDestroy++
+			}
+		}
+		if len(actions) == 1 && actions[0] == "no-op" {
+			continue
+		}
+
+		change := &pb.ResourceChange{
+			Address:       rc.Address,
+			Type:          rc.Type,
+			Name:          rc.Name,
+			ProviderName:  rc.ProviderName,
+			Actions:       actions,
+			ReplaceReason: rc.ActionReason,
+		}
+		for _, path := range rc.Change.ReplacePaths {
+			change.ReplacePaths = append(change.ReplacePaths, formatAttributePath(path))
+		}
+		result.ResourceChanges = append(result.ResourceChanges, change)
+	}
+
+	for name, oc := range plan.OutputChanges {
+		if len(oc.Actions) == 1 && oc.Actions[0] == "no-op" {
+			continue
+		}
+		change := &pb.OutputChange{
+			Name:      name,
+			Actions:   oc.Actions,
+			Sensitive: isSensitive(oc.BeforeSensitive) || isSensitive(oc.AfterSensitive),
+			Unknown:   string(oc.AfterUnknown) == "true",
+		}
+		// values of sensitive outputs never leave the runner
+		if !change.Sensitive {
+			change.Before = rawValue(oc.Before)
+			change.After = rawValue(oc.After)
+		}
+		result.OutputChanges = append(result.OutputChanges, change)
+	}
+	// map iteration order is random, keep outputs stable for clients
+	sort.Slice(result.OutputChanges, func(i, j int) bool {
+		return result.OutputChanges[i].Name < result.OutputChanges[j].Name
+	})
+	return result, nil
+}
+
+// formatAttributePath renders a replace path like ami, tags["Name"] or ingress[0]["cidr_blocks"]
+func formatAttributePath(path []any) string {
+	var b strings.Builder
+	for i, step := range path {
+		switch v := step.(type) {
+		case string:
+			if i == 0 {
+				b.WriteString(v)
+			} else {
+				fmt.Fprintf(&b, "[%q]", v)
+			}
+		case float64:
+			b.WriteString("[" + strconv.Itoa(int(v)) + "]")
+		default:
+			fmt.Fprintf(&b, "[%v]", v)
+		}
+	}
+	return b.String()
+}
+
+// isSensitive reports whether a sensitivity marker marks the whole value as sensitive
+func isSensitive(marker json.RawMessage) bool {
+	return string(marker) == "true"
+}
+
+// rawValue returns a JSON value as text, empty for null
+func rawValue(value json.RawMessage) string {
+	if len(value) == 0 || string(value) == "null" {
+		return ""
+	}
+	return string(value)
+}
+
+// loadPlanResult reads the JSON rendering and the detailed exit code the runner uploaded with a saved plan
+func (s *ExecutorService) loadPlanResult(ctx context.Context, userId, project, planId string) (*planResult, error) {
+	prefix := planPrefix(userId, project, planId)
+	content, err := s.AWSClient.GetObject(ctx, s.Bucket, prefix+"/plan.json")
+	if err != nil {
+		if errors.Is(err, awsclient.ErrObjectNotFound) {
+			return nil, fmt.Errorf("plan %s not found", planId)
+		}
+		return nil, fmt.Errorf("failed to get plan JSON: %v", err)
+	}
+	result, err := parsePlanJSON(content)
+	if err != nil {
+		return nil, err
+	}
+
+	// the run output holds the -json UI output, the human readable plan is rendered separately
+	text, err := s.AWSClient.GetObject(ctx, s.Bucket, prefix+"/plan.txt")
+	if err != nil && !errors.Is(err, awsclient.ErrObjectNotFound) {
+		return nil, fmt.Errorf("failed to get plan text: %v", err)
+	}
+	result.Text = string(text)
+
+	// -detailed-exitcode exits with 2 when the plan has changes
+	exitCode, err := s.AWSClient.GetObject(ctx, s.Bucket, prefix+"/detailed-exitcode")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan exit code: %v", err)
+	}
+	result.HasChanges = strings.TrimSpace(string(exitCode)) == "2"
+	return result, nil
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"terraform-executor/internal/awsclient"
+)
+
+// projectFiles lists the configuration files that make up a project
+var projectFiles = []string{"main.tf", "versions.tf", "variables.tf"}
+
+// planMetadata is stored next to a saved plan and used to detect stale plans
+type planMetadata struct {
+	PlanID     string    `json:"plan_id"`
+	RequestID  string    `json:"request_id"`
+	ConfigHash string    `json:"config_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// newID generates a sortable unique identifier for plans
+func newID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102150405"), hex.EncodeToString(b))
+}
+
+// planPrefix returns the S3 prefix where the artifacts of a plan are stored
+func planPrefix(userId, project, planId string) string {
+	return fmt.Sprintf("%s/%s/plans/%s", userId, project, planId)
+}
+
+// configHash returns a hash over the files of a project, see filesHash, and the runtime they run with.
+// A plan must be applied to the same files by the engine version that created it.
+func configHash(rt projectRuntime, filesHash string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "runtime\x00%s\x00%s\x00%s\x00files\x00%s\x00", rt.Engine, rt.Version, rt.ImageDigest, filesHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// currentConfigHash returns the configHash of the current files and runtime of a project
+func (s *ExecutorService) currentConfigHash(ctx context.Context, namespace, project string) (string, error) {
+	rt, err := s.getRuntime(ctx, namespace, project)
+	if err != nil {
+		return "", err
+	}
+	files, err := s.store.List(ctx, namespace, project)
+	if err != nil {
+		return "", err
+	}
+	return configHash(rt, filesHash(files)), nil
+}
+
+// savePlanMetadata records the metadata of a plan whose artifacts were uploaded by the runner
+func (s *ExecutorService) savePlanMetadata(ctx context.Context, userId, project, planId, requestId, configHash string) error {
+	content, err := json.Marshal(planMetadata{
+		PlanID:     planId,
+		RequestID:  requestId,
+		ConfigHash: configHash,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return s.AWSClient.PutObject(ctx, s.Bucket, planPrefix(userId, project, planId)+"/metadata.json", content)
+}
+
+// verifyPlan checks that a saved plan exists and was created from the configuration with the given configHash
+func (s *ExecutorService) verifyPlan(ctx context.Context, userId, project, planId, hash string) error {
+	prefix := planPrefix(userId, project, planId)
+	content, err := s.AWSClient.GetObject(ctx, s.Bucket, prefix+"/metadata.json")
+	if err != nil {
+		if errors.Is(err, awsclient.ErrObjectNotFound) {
+			return fmt.Errorf("plan %s not found", planId)
+		}
+		return fmt.Errorf("failed to get plan metadata: %v", err)
+	}
+
+	var meta planMetadata
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return fmt.Errorf("invalid plan metadata: %v", err)
+	}
+
+	exists, err := s.AWSClient.ObjectExists(ctx, s.Bucket, prefix+"/tfplan")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("plan %s not found", planId)
+	}
+
+	if hash != meta.ConfigHash {
+		return fmt.Errorf("plan %s is stale: configuration changed since it was created", planId)
+	}
+	return nil
+}
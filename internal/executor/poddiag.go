@@ -0,0 +1,225 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Reasons a runner pod failed or got stuck, recorded on the run
+const (
+	failureImagePull     = "image-pull-backoff"
+	failureConfigError   = "container-config-error"
+	failureUnschedulable = "unschedulable"
+	failurePVCNotBound   = "pvc-not-bound"
+	failurePending       = "pending-timeout"
+	failureOOMKilled     = "oom-killed"
+	failureJobTimeout    = "job-timeout"
+)
+
+var failureReasons = map[string]pb.Run_FailureReason{
+	failureImagePull:     pb.Run_IMAGE_PULL_BACKOFF,
+	failureConfigError:   pb.Run_CONTAINER_CONFIG_ERROR,
+	failureUnschedulable: pb.Run_UNSCHEDULABLE,
+	failurePVCNotBound:   pb.Run_PVC_NOT_BOUND,
+	failurePending:       pb.Run_PENDING_TIMEOUT,
+	failureOOMKilled:     pb.Run_OOM_KILLED,
+	failureJobTimeout:    pb.Run_JOB_TIMEOUT,
+}
+
+// podCheckInterval is how often the runner pod and its events are checked
+const podCheckInterval = 5 * time.Second
+
+// podThresholds configures how long a runner pod may be stuck before its run fails
+type podThresholds struct {
+	ImagePull time.Duration // image can't be pulled
+	Pending   time.Duration // pod isn't scheduled or its containers can't be created
+	Job       time.Duration // job didn't complete after its logs ended
+}
+
+// podThresholdsFromEnv reads the thresholds from POD_IMAGE_PULL_TIMEOUT, POD_PENDING_TIMEOUT and JOB_TIMEOUT ("90s", "5m")
+func podThresholdsFromEnv() (podThresholds, error) {
+	thresholds := podThresholds{
+		ImagePull: 2 * time.Minute,
+		Pending:   5 * time.Minute,
+		Job:       15 * time.Minute,
+	}
+	for env, value := range map[string]*time.Duration{
+		"POD_IMAGE_PULL_TIMEOUT": &thresholds.ImagePull,
+		"POD_PENDING_TIMEOUT":    &thresholds.Pending,
+		"JOB_TIMEOUT":            &thresholds.Job,
+	} {
+		if v := os.Getenv(env); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return thresholds, fmt.Errorf("invalid %s: %v", env, err)
+			}
+			*value = d
+		}
+	}
+	return thresholds, nil
+}
+
+// podFailure is the error of a run whose pod failed or got stuck
+type podFailure struct {
+	Reason  string
+	Message string
+}
+
+func (f *podFailure) Error() string {
+	return f.Message
+}
+
+// podMonitor tracks since when a runner pod is stuck in a condition
+type podMonitor struct {
+	thresholds podThresholds
+	firstSeen  map[string]time.Time
+}
+
+func newPodMonitor(thresholds podThresholds) *podMonitor {
+	return &podMonitor{thresholds: thresholds, firstSeen: make(map[string]time.Time)}
+}
+
+// check returns the failure of the pod, or nil if it may still complete. Terminal conditions
+// fail right away, stuck conditions once they lasted longer than their threshold.
+func (m *podMonitor) check(pod *corev1.Pod, events []corev1.Event, now time.Time) *podFailure {
+	seen := make(map[string]bool)
+	stuck := func(reason string, threshold time.Duration, message string) *podFailure {
+		seen[reason] = true
+		if _, ok := m.firstSeen[reason]; !ok {
+			m.firstSeen[reason] = now
+		}
+		if now.Sub(m.firstSeen[reason]) < threshold {
+			return nil
+		}
+		return &podFailure{Reason: reason, Message: fmt.Sprintf("%s (for more than %s)", message, threshold)}
+	}
+	defer func() {
+		for reason := range m.firstSeen {
+			if !seen[reason] {
+				delete(m.firstSeen, reason)
+			}
+		}
+	}()
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if t := status.State.Terminated; t != nil && t.Reason == "OOMKilled" {
+			return &podFailure{
+				Reason:  failureOOMKilled,
+				Message: fmt.Sprintf("container %s was killed because it ran out of memory (limit %s)", status.Name, memoryLimit(pod, status.Name)),
+			}
+		}
+		w := status.State.Waiting
+		if w == nil {
+			continue
+		}
+		switch w.Reason {
+		case "InvalidImageName":
+			return &podFailure{Reason: failureImagePull, Message: fmt.Sprintf("invalid image %s of container %s: %s", status.Image, status.Name, w.Message)}
+		case "ErrImagePull", "ImagePullBackOff":
+			if f := stuck(failureImagePull, m.thresholds.ImagePull, fmt.Sprintf("cannot pull image %s of container %s: %s", status.Image, status.Name, w.Message)); f != nil {
+				return f
+			}
+		case "CreateContainerConfigError", "CreateContainerError":
+			if f := stuck(failureConfigError, m.thresholds.Pending, fmt.Sprintf("cannot create container %s: %s", status.Name, w.Message)); f != nil {
+				return f
+			}
+		}
+	}
+
+	if pod.Status.Phase != corev1.PodPending {
+		return nil
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type != corev1.PodScheduled || cond.Status != corev1.ConditionFalse || cond.Reason != corev1.PodReasonUnschedulable {
+			continue
+		}
+		reason := failureUnschedulable
+		if strings.Contains(cond.Message, "PersistentVolumeClaim") {
+			reason = failurePVCNotBound
+		}
+		return stuck(reason, m.thresholds.Pending, fmt.Sprintf("pod %s cannot be scheduled: %s", pod.Name, cond.Message))
+	}
+
+	// an init container started, the pod is running them, e.g. fetching the files or restoring the plan
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.State.Running != nil || status.State.Terminated != nil {
+			return nil
+		}
+	}
+
+	// scheduled but the containers don't start, the latest warning event tells why
+	message := fmt.Sprintf("pod %s is pending", pod.Name)
+	reason := failurePending
+	var latest *corev1.Event
+	for i := range events {
+		e := &events[i]
+		if e.Type == corev1.EventTypeWarning && (latest == nil || e.LastTimestamp.After(latest.LastTimestamp.Time)) {
+			latest = e
+		}
+	}
+	if latest != nil {
+		message = fmt.Sprintf("%s: %s: %s", message, latest.Reason, latest.Message)
+		if latest.Reason == "FailedMount" && strings.Contains(strings.ToLower(latest.Message), "persistentvolumeclaim") {
+			reason = failurePVCNotBound
+		}
+	}
+	return stuck(reason, m.thresholds.Pending, message)
+}
+
+// memoryLimit returns the memory limit of a container of the pod
+func memoryLimit(pod *corev1.Pod, container string) string {
+	for _, c := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if c.Name == container {
+			if limit, ok := c.Resources.Limits[corev1.ResourceMemory]; ok {
+				return limit.String()
+			}
+		}
+	}
+	return "none"
+}
+
+// monitorPod checks the runner pod of a run and its events until the context is cancelled.
+// It reports the first failure and cancels the wait for the run.
+func (s *ExecutorService) monitorPod(ctx context.Context, r *run, cancel context.CancelFunc, failed chan<- *podFailure) {
+	monitor := newPodMonitor(s.podThresholds)
+	ticker := time.NewTicker(podCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pod, err := s.K8sClient.GetJobPod(ctx, r.UserID, r.JobName)
+		if err != nil {
+			continue
+		}
+		events, err := s.K8sClient.ListEvents(ctx, r.UserID, fmt.Sprintf("involvedObject.name=%s", pod.Name))
+		if err != nil {
+			fmt.Printf("⚠️ Failed to list events of pod %s: %v\n", pod.Name, err)
+			events = &corev1.EventList{}
+		}
+		if f := monitor.check(pod, events.Items, time.Now()); f != nil {
+			fmt.Printf("❌ Run %s failed: %s\n", r.ID, f.Message)
+			failed <- f
+			cancel()
+			return
+		}
+	}
+}
+
+// terminalPodFailure returns the failure of a completed pod terraform couldn't report, like an OOM kill
+func (s *ExecutorService) terminalPodFailure(pod *corev1.Pod) *podFailure {
+	f := newPodMonitor(s.podThresholds).check(pod, nil, time.Now())
+	if f != nil && f.Reason == failureOOMKilled {
+		return f
+	}
+	return nil
+}
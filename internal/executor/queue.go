@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// lockTTL bounds how long a crashed executor can hold the scheduler lock
+	lockTTL = time.Minute
+	// queueInterval is how often queued runs are re-scheduled
+	queueInterval = 5 * time.Second
+	// schedulerLockName is the ConfigMap serializing scheduling decisions across executor replicas
+	schedulerLockName = "terraform-executor.scheduler"
+)
+
+// acquireLock takes a lock ConfigMap in the executor namespace and returns it.
+// It returns nil if another executor holds the lock. The ConfigMap is kept
+// between holders so it can carry state in its annotations.
+func (s *ExecutorService) acquireLock(ctx context.Context, name string) (*corev1.ConfigMap, error) {
+	holder, _ := os.Hostname()
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	cm, err := s.K8sClient.GetConfigMap(ctx, s.Namespace, name)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get lock %s: %v", name, err)
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					"app":       "terraform-executor",
+					"component": "lock",
+				},
+				Annotations: map[string]string{
+					"holder":      holder,
+					"acquired-at": now,
+				},
+			},
+		}
+		if err := s.K8sClient.CreateConfigMap(ctx, s.Namespace, cm); err != nil {
+			if k8serrors.IsAlreadyExists(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to create lock %s: %v", name, err)
+		}
+		return cm, nil
+	}
+
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	if cm.Annotations["holder"] != "" {
+		acquiredAt, err := time.Parse(time.RFC3339, cm.Annotations["acquired-at"])
+		if err == nil && time.Since(acquiredAt) < lockTTL {
+			return nil, nil
+		}
+		// the holder crashed, take over the expired lock
+	}
+
+	// the update fails if another executor took the lock first
+	cm.Annotations["holder"] = holder
+	cm.Annotations["acquired-at"] = now
+	if err := s.K8sClient.UpdateConfigMap(ctx, s.Namespace, cm); err != nil {
+		if k8serrors.IsConflict(err) || k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to take lock %s: %v", name, err)
+	}
+	return s.K8sClient.GetConfigMap(ctx, s.Namespace, name)
+}
+
+// waitLock retries to take a lock for a few seconds
+func (s *ExecutorService) waitLock(ctx context.Context, name string) (*corev1.ConfigMap, error) {
+	for i := 0; i < 20; i++ {
+		lock, err := s.acquireLock(ctx, name)
+		if err != nil || lock != nil {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	return nil, fmt.Errorf("lock %s is busy, retry later", name)
+}
+
+// releaseLock releases a lock taken with acquireLock, keeping the other annotations of the lock
+func (s *ExecutorService) releaseLock(ctx context.Context, lock *corev1.ConfigMap) {
+	lock.Annotations["holder"] = ""
+	if err := s.K8sClient.UpdateConfigMap(ctx, s.Namespace, lock); err != nil {
+		fmt.Printf("⚠️ Failed to release lock %s: %v\n", lock.Name, err)
+	}
+}
+
+// runnable returns the queued runs of a project that may start next to its active ones.
+// Runs start in FIFO order, mutating runs execute one at a time while
+// read-only runs may run in parallel. A queued mutating run blocks the runs
+// queued after it so it can't be starved by read-only runs. Offline runs
+// start right away, they neither block the other runs nor are blocked.
+func runnable(active, queued []*run) []*run {
+	mutatingActive, busy := false, false
+	for _, r := range active {
+		if r.mutating() {
+			mutatingActive = true
+		}
+		busy = busy || !r.offline()
+	}
+
+	var start []*run
+	blocked := false
+	for _, r := range queued {
+		if r.offline() {
+			// offline runs touch neither the state nor the backend, they don't wait for the other runs
+			start = append(start, r)
+			continue
+		}
+		if blocked || mutatingActive || (r.mutating() && busy) {
+			blocked = true
+			continue
+		}
+		if r.mutating() {
+			mutatingActive = true
+		}
+		busy = true
+		start = append(start, r)
+	}
+	return start
+}
+
+// jobActive reports whether a runner Job is still running
+func jobActive(job *batchv1.Job) bool {
+	return job.Status.Succeeded == 0 && job.Status.Failed == 0 && job.DeletionTimestamp == nil
+}
+
+// prepareRunJob resolves the settings the runner Job of a queued run needs. It runs before the
+// scheduler lock is taken, for every run a pass may start, so it only looks things up.
+func (s *ExecutorService) prepareRunJob(ctx context.Context, r *run) error {
+	// the run may have waited long enough for the credentials to expire,
+	// offline runs need them to fetch the files of a store in S3
+	if !r.offline() || s.store.Credentials() {
+		if err := s.ensureAWSCredentials(ctx, r.UserID); err != nil {
+			return fmt.Errorf("AWS credentials error: %w", err)
+		}
+	}
+	// the runtime is resolved when the Job is created so a queued run picks up changes
+	rt, err := s.getRuntime(ctx, r.UserID, r.Project)
+	if err != nil {
+		return err
+	}
+	r.Runtime, r.Engine, r.Version, r.Image = rt, rt.Engine, rt.Version, rt.image()
+
+	// a project bound to git runs the files of its last sync
+	binding, err := s.getGitBinding(ctx, r.UserID, r.Project)
+	if err != nil {
+		return err
+	}
+	if binding != nil {
+		if binding.Commit == "" {
+			return fmt.Errorf("project %s is bound to %s but wasn't synced yet, call SyncFromGit first", r.Project, binding.URL)
+		}
+		r.Commit = binding.Commit
+	}
+	return nil
+}
+
+// runRevision returns the revision a run executes, the latest one as every mutation records one.
+// Otherwise the current files are recorded through the claim of the next revision like a mutation,
+// they can't be recorded while a mutation is changing them.
+func (s *ExecutorService) runRevision(ctx context.Context, r *run) (*revision, error) {
+	rev, err := s.currentRevision(ctx, r.UserID, r.Project)
+	if err != nil || rev != nil {
+		return rev, err
+	}
+	// projects created before revisions were recorded get their first one here
+	c := change{RequestID: r.RequestID, Action: "StartRun", Commit: r.Commit}
+	number, err := s.mutateRevision(ctx, r.UserID, r.Project, 0, c, func() error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return s.revisionRecord(ctx, r.UserID, r.Project, number)
+}
+
+// startRunJob binds a run prepared by prepareRunJob to its state and files and creates its runner Job.
+// It runs under the scheduler lock, only for the runs the pass starts.
+func (s *ExecutorService) startRunJob(ctx context.Context, r *run) error {
+	if r.mutating() {
+		// mutating runs of a project are serialized, the state can't change until the Job runs
+		s.snapshotState(ctx, r, "state-pre.tfstate")
+	}
+
+	rev, err := s.runRevision(ctx, r)
+	if err != nil {
+		return err
+	}
+	r.Revision = rev.Number
+
+	// the Job mounts the files of the revision, a plan is bound to them and the runtime
+	hash := configHash(r.Runtime, rev.Hash)
+	switch {
+	case r.Type == "plan":
+		// Remember which configuration the plan is created from so Apply can detect stale plans
+		r.ConfigHash = hash
+	case r.Type == "apply" && r.PlanID != "":
+		if err := s.verifyPlan(ctx, r.UserID, r.Project, r.PlanID, hash); err != nil {
+			return err
+		}
+	}
+
+	r.JobName = fmt.Sprintf("terraform-%s-%s", r.Type, r.ID)
+	job, err := s.createTerraformJobTemplate(ctx, r)
+	if err != nil {
+		return fmt.Errorf("job creation error: %v", err)
+	}
+	// the job name is derived from the run, an existing job was created by an earlier attempt
+	if _, err := s.K8sClient.CreateJob(ctx, r.UserID, job); err != nil && !k8serrors.IsAlreadyExists(err) {
+		if s.Debug {
+			fmt.Printf("Job template that failed:\n%s\n", s.redactSecrets(ctx, r.UserID, r.Project, fmt.Sprintf("%+v", job)))
+		}
+		return fmt.Errorf("kubernetes job error: %v", err)
+	}
+
+	r.Phase = runPhaseInitializing
+	r.QueuePos = 0
+	r.QueueReason = ""
+	r.StartedAt = time.Now().UTC()
+	if err := s.saveRun(ctx, r); err != nil {
+		fmt.Printf("⚠️ Failed to save run %s: %v\n", r.ID, err)
+	}
+	return nil
+}
+
+// runQueueLoop periodically schedules the queued runs. It picks up runs
+// queued before a restart and runs unblocked by other replicas.
+func (s *ExecutorService) runQueueLoop() {
+	ticker := time.NewTicker(queueInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.schedule(s.ctx); err != nil {
+				fmt.Printf("⚠️ Failed to schedule runs: %v\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// redacted replaces the secret values in debug output
+	redacted = "[REDACTED]"
+	// minRedacted is the length of the shortest value redacted, shorter ones would hit any word or number
+	minRedacted = 4
+)
+
+// secretValues returns the values of the secret env variables and the strings held by the variables
+// declared sensitive, values shorter than minRedacted are left out.
+func (s *ExecutorService) secretValues(ctx context.Context, namespace, project string) []string {
+	var values []string
+	if secret, err := s.K8sClient.GetSecret(ctx, namespace, envSecretName(project)); err == nil {
+		for _, value := range secret.Data {
+			values = append(values, string(value))
+		}
+	}
+	variables, err := s.store.Get(ctx, namespace, project, "variables.tf")
+	vars, varsErr := s.getVarValues(ctx, namespace, project)
+	if err == nil && varsErr == nil {
+		sensitive := sensitiveVariables(variables.Content)
+		for name, raw := range vars {
+			var value interface{}
+			if !sensitive[name] || json.Unmarshal(raw, &value) != nil {
+				continue
+			}
+			values = appendStrings(values, value)
+		}
+	}
+	return slices.DeleteFunc(values, func(value string) bool {
+		return len(value) < minRedacted
+	})
+}
+
+// appendStrings appends the strings of a decoded JSON value, including those nested in objects and lists
+func appendStrings(values []string, value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		values = append(values, v)
+	case []interface{}:
+		for _, elem := range v {
+			values = appendStrings(values, elem)
+		}
+	case map[string]interface{}:
+		for _, elem := range v {
+			values = appendStrings(values, elem)
+		}
+	}
+	return values
+}
+
+// redactSecrets strips the secret values of a project from debug output. Values that can't be
+// read aren't redacted, the debug output is printed anyway.
+func (s *ExecutorService) redactSecrets(ctx context.Context, namespace, project, text string) string {
+	values := s.secretValues(ctx, namespace, project)
+	// a value containing another one is replaced first
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, redacted)
+	}
+	return text
+}
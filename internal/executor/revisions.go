@@ -0,0 +1,469 @@
+package executor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+)
+
+// maxRevisions is the number of revisions kept per project, older ones are pruned
+const maxRevisions = 100
+
+// revision is an immutable snapshot of the project files recorded after a mutation
+type revision struct {
+	Number       int64     `json:"number"`
+	CreatedAt    time.Time `json:"created_at"`
+	RequestID    string    `json:"request_id,omitempty"`
+	Author       string    `json:"author,omitempty"`
+	Action       string    `json:"action"` // RPC that created the revision, e.g. AppendCode
+	Hash         string    `json:"hash"`
+	RestoredFrom int64     `json:"restored_from,omitempty"`
+	Paths        []string  `json:"files"`
+	Store        string    `json:"store,omitempty"`  // configStore keeping the files, empty if they are stored next to the record
+	Commit       string    `json:"commit,omitempty"` // git commit the files were synced from
+
+	// Files are loaded with getRevision, missing files didn't exist
+	Files map[string]string `json:"-"`
+	// SecretVars are the values AddSecretVar wrote into variables.tf in earlier versions,
+	// getRevision removes them from Files
+	SecretVars map[string]json.RawMessage `json:"-"`
+}
+
+func (rev *revision) toProto() *pb.Revision {
+	out := &pb.Revision{
+		Number:       rev.Number,
+		CreatedAt:    timestamppb.New(rev.CreatedAt),
+		RequestId:    rev.RequestID,
+		Author:       rev.Author,
+		Action:       rev.Action,
+		Hash:         rev.Hash,
+		RestoredFrom: rev.RestoredFrom,
+		Files:        rev.Paths,
+		Commit:       rev.Commit,
+	}
+	return out
+}
+
+// revisionConfigMapName returns the name of the ConfigMap holding a revision
+func revisionConfigMapName(project string, number int64) string {
+	return fmt.Sprintf("%s.revision.%d", project, number)
+}
+
+// filesHash returns the content hash of a set of project files
+func filesHash(files map[string]string) string {
+	h := sha256.New()
+	for _, p := range sortedPaths(files) {
+		fmt.Fprintf(h, "%s\x00%s\x00", p, files[p])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// revisionFromConfigMap decodes a revision, the files of a store are not loaded
+func revisionFromConfigMap(cm *corev1.ConfigMap) (*revision, error) {
+	rev := &revision{}
+	if err := json.Unmarshal([]byte(cm.Data["revision.json"]), rev); err != nil {
+		return nil, fmt.Errorf("invalid revision %s: %v", cm.Name, err)
+	}
+	if rev.Store == "" {
+		rev.Files = decodeFiles(cm.Data)
+		rev.Paths = sortedPaths(rev.Files)
+	}
+	return rev, nil
+}
+
+// listRevisions loads the revisions of a project, newest first
+func (s *ExecutorService) listRevisions(ctx context.Context, namespace, project string) ([]*revision, error) {
+	cms, err := s.K8sClient.ListConfigMaps(ctx, namespace, "app=terraform-executor,component=revision,project="+project)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %v", err)
+	}
+	revs := make([]*revision, 0, len(cms.Items))
+	for i := range cms.Items {
+		rev, err := revisionFromConfigMap(&cms.Items[i])
+		if err != nil {
+			fmt.Printf("⚠️ Skipping revision: %v\n", err)
+			continue
+		}
+		revs = append(revs, rev)
+	}
+	sort.Slice(revs, func(i, j int) bool {
+		return revs[i].Number > revs[j].Number
+	})
+	return revs, nil
+}
+
+// latestRevision returns the number of the latest revision of a project, 0 if it has none
+func (s *ExecutorService) latestRevision(ctx context.Context, namespace, project string) (int64, error) {
+	revs, err := s.listRevisions(ctx, namespace, project)
+	if err != nil || len(revs) == 0 {
+		return 0, err
+	}
+	return revs[0].Number, nil
+}
+
+// currentRevision returns the latest revision of a project if it holds the current files, nil otherwise
+func (s *ExecutorService) currentRevision(ctx context.Context, namespace, project string) (*revision, error) {
+	files, err := s.store.List(ctx, namespace, project)
+	if err != nil {
+		return nil, err
+	}
+	revs, err := s.listRevisions(ctx, namespace, project)
+	if err != nil || len(revs) == 0 || revs[0].Hash != filesHash(files) {
+		return nil, err
+	}
+	return revs[0], nil
+}
+
+// revisionRecord loads a revision of a project without the files of a store
+func (s *ExecutorService) revisionRecord(ctx context.Context, namespace, project string, number int64) (*revision, error) {
+	cm, err := s.K8sClient.GetConfigMap(ctx, namespace, revisionConfigMapName(project, number))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("revision %d not found", number)
+		}
+		return nil, fmt.Errorf("failed to get revision: %v", err)
+	}
+	return revisionFromConfigMap(cm)
+}
+
+// getRevision loads a revision of a project with its files
+func (s *ExecutorService) getRevision(ctx context.Context, namespace, project string, number int64) (*revision, error) {
+	rev, err := s.revisionRecord(ctx, namespace, project, number)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Store != "" {
+		if rev.Store != s.store.Name() {
+			return nil, fmt.Errorf("revision %d is kept by the %s store, the executor uses the %s store", number, rev.Store, s.store.Name())
+		}
+		if rev.Files, err = s.store.ReadSnapshot(ctx, namespace, project, rev.Hash); err != nil {
+			return nil, fmt.Errorf("failed to read revision %d: %v", number, err)
+		}
+	}
+	// revisions recorded before the values were stored in the Secret hold them in variables.tf
+	rev.SecretVars = scrubSecretVars(rev.Files)
+	return rev, nil
+}
+
+// change describes a mutation in the revision recording it
+type change struct {
+	RequestID    string
+	Author       string
+	Action       string // RPC of the mutation, e.g. AppendCode
+	RestoredFrom int64
+	Commit       string
+}
+
+// recordRevision snapshots the current project files as a new revision. Nothing is recorded
+// when they didn't change since the latest revision, which is returned instead. A mutation
+// records into the revision it claimed, see claimRevision, which is released if nothing changed.
+func (s *ExecutorService) recordRevision(ctx context.Context, namespace, project string, c change, claim *corev1.ConfigMap) (*revision, error) {
+	var rev *revision
+	var revs []*revision
+	// the revision number is taken by creating its ConfigMap, retry when another mutation took it first
+	err := retry.OnError(mutationBackoff, k8serrors.IsAlreadyExists, func() error {
+		files, err := s.store.List(ctx, namespace, project)
+		if err != nil {
+			return err
+		}
+		revs, err = s.listRevisions(ctx, namespace, project)
+		if err != nil {
+			return err
+		}
+		hash := filesHash(files)
+		if len(revs) > 0 && revs[0].Hash == hash {
+			rev = revs[0]
+			if claim != nil {
+				s.releaseRevision(ctx, namespace, claim)
+			}
+			return nil
+		}
+
+		rev = &revision{
+			Number:       1,
+			CreatedAt:    time.Now().UTC(),
+			RequestID:    c.RequestID,
+			Author:       c.Author,
+			Action:       c.Action,
+			Hash:         hash,
+			RestoredFrom: c.RestoredFrom,
+			Commit:       c.Commit,
+			Paths:        sortedPaths(files),
+			Files:        files,
+		}
+		if len(revs) > 0 {
+			rev.Number = revs[0].Number + 1
+		}
+		if claim != nil {
+			rev.Number, _ = strconv.ParseInt(claim.Labels["revision"], 10, 64)
+		}
+		data, err := s.store.Snapshot(ctx, namespace, project, hash, files)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			data = make(map[string]string)
+			rev.Store = s.store.Name()
+		}
+		record, err := json.Marshal(rev)
+		if err != nil {
+			return err
+		}
+		data["revision.json"] = string(record)
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   revisionConfigMapName(project, rev.Number),
+				Labels: revisionLabels(project, rev.Number, "revision"),
+			},
+			Immutable: ptr.To(true),
+			Data:      data,
+		}
+		if claim != nil {
+			// the update fails if the claim was taken over as abandoned
+			cm.ResourceVersion = claim.ResourceVersion
+			return s.K8sClient.UpdateConfigMap(ctx, namespace, cm)
+		}
+		return s.K8sClient.CreateConfigMap(ctx, namespace, cm)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %v", err)
+	}
+
+	// revs holds the revisions before the new one
+	kept := map[string]bool{rev.Hash: true}
+	var pruned []*revision
+	for _, old := range revs {
+		if rev.Number-old.Number < maxRevisions {
+			kept[old.Hash] = true
+			continue
+		}
+		if err := s.K8sClient.DeleteConfigMap(ctx, namespace, revisionConfigMapName(project, old.Number)); err != nil && !k8serrors.IsNotFound(err) {
+			fmt.Printf("⚠️ Failed to prune revision %d of project %s: %v\n", old.Number, project, err)
+			continue
+		}
+		pruned = append(pruned, old)
+	}
+	// snapshots are shared by the revisions with the same files
+	for _, old := range pruned {
+		if old.Store == "" || kept[old.Hash] {
+			continue
+		}
+		kept[old.Hash] = true
+		if err := s.store.DeleteSnapshot(ctx, namespace, project, old.Hash); err != nil {
+			fmt.Printf("⚠️ Failed to prune the files of revision %d of project %s: %v\n", old.Number, project, err)
+		}
+	}
+	return rev, nil
+}
+
+// revisionLabels returns the labels of a revision ConfigMap, component is revision or revision-claim
+func revisionLabels(project string, number int64, component string) map[string]string {
+	return map[string]string{
+		"app":       "terraform-executor",
+		"component": component,
+		"project":   project,
+		"revision":  strconv.FormatInt(number, 10),
+	}
+}
+
+// claimRevision takes the number of the next revision of a project before a mutation changes the files,
+// by creating the ConfigMap of the revision. It fails with AlreadyExists if another mutation took it.
+// The claim becomes the revision recorded by recordRevision. A claim left behind by a crashed
+// executor is taken over after claimTTL.
+func (s *ExecutorService) claimRevision(ctx context.Context, namespace, project string, number int64) (*corev1.ConfigMap, error) {
+	name := revisionConfigMapName(project, number)
+	now := time.Now().UTC().Format(time.RFC3339)
+	err := s.K8sClient.CreateConfigMap(ctx, namespace, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      revisionLabels(project, number, "revision-claim"),
+			Annotations: map[string]string{"claimed-at": now},
+		},
+	})
+	if k8serrors.IsAlreadyExists(err) {
+		existing, getErr := s.K8sClient.GetConfigMap(ctx, namespace, name)
+		if getErr != nil {
+			return nil, err
+		}
+		claimedAt, parseErr := time.Parse(time.RFC3339, existing.Annotations["claimed-at"])
+		if existing.Labels["component"] != "revision-claim" || parseErr != nil || time.Since(claimedAt) < claimTTL {
+			return nil, fmt.Errorf("revision %d of project %s is taken by another change: %w", number, project, err)
+		}
+		// the update fails if another mutation took over the abandoned claim first
+		existing.Annotations["claimed-at"] = now
+		err = s.K8sClient.UpdateConfigMap(ctx, namespace, existing)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.K8sClient.GetConfigMap(ctx, namespace, name)
+}
+
+// releaseRevision gives up a claim without recording a revision, unless it was taken over since
+func (s *ExecutorService) releaseRevision(ctx context.Context, namespace string, claim *corev1.ConfigMap) {
+	err := s.K8sClient.DeleteConfigMapVersion(ctx, namespace, claim.Name, claim.ResourceVersion)
+	if err != nil && !k8serrors.IsNotFound(err) && !k8serrors.IsConflict(err) {
+		fmt.Printf("⚠️ Failed to release revision claim %s: %v\n", claim.Name, err)
+	}
+}
+
+// deleteRevisions removes the revision history of a project
+func (s *ExecutorService) deleteRevisions(ctx context.Context, namespace, project string) error {
+	revs, err := s.listRevisions(ctx, namespace, project)
+	if err != nil {
+		return err
+	}
+	for _, rev := range revs {
+		if err := s.K8sClient.DeleteConfigMap(ctx, namespace, revisionConfigMapName(project, rev.Number)); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete revision %d: %v", rev.Number, err)
+		}
+	}
+	// claims left behind by crashed executors
+	claims, err := s.K8sClient.ListConfigMaps(ctx, namespace, "app=terraform-executor,component=revision-claim,project="+project)
+	if err != nil {
+		return fmt.Errorf("failed to list revision claims: %v", err)
+	}
+	for _, claim := range claims.Items {
+		if err := s.K8sClient.DeleteConfigMap(ctx, namespace, claim.Name); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete revision claim %s: %v", claim.Name, err)
+		}
+	}
+	return nil
+}
+
+// splitLines splits a file into lines keeping their line breaks, as the diff expects
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// diffFiles returns the unified diff of each project file that differs between two revisions
+func diffFiles(from, to *revision) ([]*pb.FileDiff, error) {
+	var diffs []*pb.FileDiff
+	paths := make(map[string]string)
+	for p := range from.Files {
+		paths[p] = ""
+	}
+	for p := range to.Files {
+		paths[p] = ""
+	}
+	for _, file := range sortedPaths(paths) {
+		a, b := from.Files[file], to.Files[file]
+		if a == b {
+			continue
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        splitLines(a),
+			B:        splitLines(b),
+			FromFile: fmt.Sprintf("%s (revision %d)", file, from.Number),
+			ToFile:   fmt.Sprintf("%s (revision %d)", file, to.Number),
+			Context:  3,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %v", file, err)
+		}
+		diffs = append(diffs, &pb.FileDiff{File: file, Diff: diff})
+	}
+	return diffs, nil
+}
+
+// ListRevisions lists the revisions of the project configuration, newest first.
+func (s *ExecutorService) ListRevisions(ctx context.Context, req *pb.ListRevisionsRequest) (*pb.ListRevisionsResponse, error) {
+	revs, err := s.listRevisions(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.ListRevisionsResponse{Success: false, Error: err.Error()}, nil
+	}
+	resp := &pb.ListRevisionsResponse{Success: true}
+	for _, rev := range revs {
+		resp.Revisions = append(resp.Revisions, rev.toProto())
+	}
+	return resp, nil
+}
+
+// DiffRevisions returns the unified diff of the project files between two revisions.
+func (s *ExecutorService) DiffRevisions(ctx context.Context, req *pb.DiffRevisionsRequest) (*pb.DiffRevisionsResponse, error) {
+	toNumber := req.ToRevision
+	if toNumber == 0 {
+		revs, err := s.listRevisions(ctx, req.UserId, req.Project)
+		if err != nil {
+			return &pb.DiffRevisionsResponse{Success: false, Error: err.Error()}, nil
+		}
+		if len(revs) == 0 {
+			return &pb.DiffRevisionsResponse{Success: false, Error: fmt.Sprintf("project %s has no revisions", req.Project)}, nil
+		}
+		toNumber = revs[0].Number
+	}
+	to, err := s.getRevision(ctx, req.UserId, req.Project, toNumber)
+	if err != nil {
+		return &pb.DiffRevisionsResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	// the first revision is compared to an empty project
+	from := &revision{Number: 0}
+	if fromNumber := req.FromRevision; fromNumber != 0 || toNumber > 1 {
+		if fromNumber == 0 {
+			fromNumber = toNumber - 1
+		}
+		if from, err = s.getRevision(ctx, req.UserId, req.Project, fromNumber); err != nil {
+			return &pb.DiffRevisionsResponse{Success: false, Error: err.Error()}, nil
+		}
+	}
+
+	diffs, err := diffFiles(from, to)
+	if err != nil {
+		return &pb.DiffRevisionsResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.DiffRevisionsResponse{
+		Success:      true,
+		FromRevision: from.Number,
+		ToRevision:   to.Number,
+		Diffs:        diffs,
+	}, nil
+}
+
+// RestoreRevision restores the project files of a revision and records them as a new revision.
+func (s *ExecutorService) RestoreRevision(ctx context.Context, req *pb.RestoreRevisionRequest) (*pb.RestoreRevisionResponse, error) {
+	rev, err := s.getRevision(ctx, req.UserId, req.Project, req.Revision)
+	if err != nil {
+		return &pb.RestoreRevisionResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	c := change{RequestID: req.RequestId, Author: req.Author, Action: "RestoreRevision", RestoredFrom: rev.Number}
+	number, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, c, func() error {
+		// values written into variables.tf by earlier versions don't replace the values set since
+		if len(rev.SecretVars) > 0 {
+			if err := s.putVarValues(ctx, req.UserId, req.Project, rev.SecretVars, false); err != nil {
+				return err
+			}
+		}
+		return s.replaceFiles(ctx, req.UserId, req.Project, rev.Files)
+	})
+	if err != nil {
+		return &pb.RestoreRevisionResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+
+	restored, err := s.revisionRecord(ctx, req.UserId, req.Project, number)
+	if err != nil {
+		return &pb.RestoreRevisionResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.RestoreRevisionResponse{Success: true, Revision: restored.toProto()}, nil
+}
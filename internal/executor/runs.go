@@ -0,0 +1,605 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Run phases as stored in the run records
+const (
+	runPhaseQueued       = "queued"
+	runPhaseInitializing = "initializing"
+	runPhasePlanning     = "planning"
+	runPhaseApplying     = "applying"
+	runPhaseSucceeded    = "succeeded"
+	runPhaseFailed       = "failed"
+	runPhaseCancelled    = "cancelled"
+)
+
+// maxRunOutput limits the output kept in a run record so it fits into a ConfigMap
+const maxRunOutput = 512 * 1024
+
+// maxRuns is the number of finished runs kept per project, older ones are pruned with their logs and artifacts
+const maxRuns = 100
+
+// runTypes maps the API run types to the type labels used on runner Jobs
+var runTypes = map[pb.Run_Type]string{
+	pb.Run_PLAN:       "plan",
+	pb.Run_APPLY:      "apply",
+	pb.Run_DESTROY:    "destroy",
+	pb.Run_STATE_LIST: "state-list",
+	pb.Run_SHOW:       "show",
+	pb.Run_VALIDATE:   "validate",
+}
+
+var runPhases = map[string]pb.Run_Phase{
+	runPhaseQueued:       pb.Run_QUEUED,
+	runPhaseInitializing: pb.Run_INITIALIZING,
+	runPhasePlanning:     pb.Run_PLANNING,
+	runPhaseApplying:     pb.Run_APPLYING,
+	runPhaseSucceeded:    pb.Run_SUCCEEDED,
+	runPhaseFailed:       pb.Run_FAILED,
+	runPhaseCancelled:    pb.Run_CANCELLED,
+}
+
+// run is the persisted record of a single Terraform execution
+type run struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Project     string    `json:"project"`
+	RequestID   string    `json:"request_id"`
+	Type        string    `json:"type"`
+	Phase       string    `json:"phase"`
+	PlanID      string    `json:"plan_id,omitempty"`
+	ConfigHash  string    `json:"config_hash,omitempty"`
+	JobName     string    `json:"job_name,omitempty"`
+	Engine      string    `json:"engine,omitempty"`  // engine the Job was created with
+	Version     string    `json:"version,omitempty"` // engine version the Job was created with
+	Image       string    `json:"image,omitempty"`   // runner image the Job was created with
+	CreatedAt   time.Time `json:"created_at"`
+	QueuePos    int32     `json:"queue_position,omitempty"`
+	QueueReason string    `json:"queue_reason,omitempty"` // why a queued run didn't start yet
+	StartedAt   time.Time `json:"started_at,omitempty"`
+	FinishedAt  time.Time `json:"finished_at,omitempty"`
+	ExitCode    int32     `json:"exit_code"`
+	Error       string    `json:"error,omitempty"`
+	Failure     string    `json:"failure_reason,omitempty"` // why the runner pod failed or got stuck
+
+	Revision    int64            `json:"revision,omitempty"`    // revision of the configuration the Job was created with
+	Commit      string           `json:"commit,omitempty"`      // git commit of the revision, if the project is bound to a repository
+	Diagnostics []*pb.Diagnostic `json:"diagnostics,omitempty"` // errors and warnings reported by terraform
+
+	// Output and CancelledBy are stored outside of the JSON record
+	Output      string `json:"-"`
+	CancelledBy string `json:"-"`
+
+	// Runtime is resolved by prepareRunJob, the record keeps its Engine, Version and Image
+	Runtime projectRuntime `json:"-"`
+}
+
+// newRun creates a queued run record
+func newRun(userId, project, requestId, runType string) *run {
+	return &run{
+		ID:        newID(),
+		UserID:    userId,
+		Project:   project,
+		RequestID: requestId,
+		Type:      runType,
+		Phase:     runPhaseQueued,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// finished reports whether the run reached a final phase
+func (r *run) finished() bool {
+	return r.Phase == runPhaseSucceeded || r.Phase == runPhaseFailed || r.Phase == runPhaseCancelled
+}
+
+// mutating reports whether the run changes infrastructure or state
+func (r *run) mutating() bool {
+	return r.Type == "apply" || r.Type == "destroy"
+}
+
+// offline reports whether the run needs neither AWS credentials nor the backend
+func (r *run) offline() bool {
+	return r.Type == "validate"
+}
+
+// binary returns the engine executable of the run
+func (r *run) binary() string {
+	if r.Engine == engineTofu {
+		return engineTofu
+	}
+	return engineTerraform
+}
+
+// args returns the terraform arguments executed by the run.
+// Plan, apply and destroy use the -json UI output which is decoded into events.
+func (r *run) args() []string {
+	switch r.Type {
+	case "plan":
+		// exits with 2 when there are changes
+		return []string{"plan", "-input=false", "-no-color", "-json", "-detailed-exitcode"}
+	case "apply":
+		if r.PlanID != "" {
+			// a saved plan doesn't need approval
+			return []string{"apply", "-input=false", "-no-color", "-json"}
+		}
+		return []string{"apply", "-auto-approve", "-input=false", "-no-color", "-json"}
+	case "destroy":
+		return []string{"destroy", "-auto-approve", "-input=false", "-no-color", "-json"}
+	case "state-list":
+		return []string{"state", "list", "-no-color"}
+	case "validate":
+		return []string{"validate", "-json", "-no-color"}
+	default:
+		return []string{"show", "-json", "-no-color"}
+	}
+}
+
+func (r *run) toProto(withOutput bool) *pb.Run {
+	out := &pb.Run{
+		RunId:         r.ID,
+		UserId:        r.UserID,
+		Project:       r.Project,
+		RequestId:     r.RequestID,
+		Phase:         runPhases[r.Phase],
+		PlanId:        r.PlanID,
+		CreatedAt:     timestamppb.New(r.CreatedAt),
+		ExitCode:      r.ExitCode,
+		QueuePosition: r.QueuePos,
+		QueueReason:   r.QueueReason,
+		Engine:        r.Engine,
+		EngineVersion: r.Version,
+		Error:         r.Error,
+		FailureReason: failureReasons[r.Failure],
+		Diagnostics:   r.Diagnostics,
+		Revision:      r.Revision,
+		Commit:        r.Commit,
+		CancelledBy:   r.CancelledBy,
+	}
+	for t, name := range runTypes {
+		if name == r.Type {
+			out.Type = t
+		}
+	}
+	if !r.StartedAt.IsZero() {
+		out.StartedAt = timestamppb.New(r.StartedAt)
+	}
+	if !r.FinishedAt.IsZero() {
+		out.FinishedAt = timestamppb.New(r.FinishedAt)
+	}
+	if withOutput {
+		out.Output = r.Output
+	}
+	return out
+}
+
+// runConfigMapName returns the name of the ConfigMap holding a run record
+func runConfigMapName(project, runId string) string {
+	return fmt.Sprintf("%s.run.%s", project, runId)
+}
+
+// runLabels returns the labels used to find run records
+func runLabels(r *run) map[string]string {
+	return map[string]string{
+		"app":       "terraform-executor",
+		"component": "run",
+		"project":   r.Project,
+		"run-id":    r.ID,
+		"type":      r.Type,
+		"phase":     r.Phase,
+	}
+}
+
+// saveRun creates or updates the ConfigMap holding the run record
+func (s *ExecutorService) saveRun(ctx context.Context, r *run) error {
+	record, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	output := r.Output
+	if len(output) > maxRunOutput {
+		output = output[len(output)-maxRunOutput:]
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   runConfigMapName(r.Project, r.ID),
+			Labels: runLabels(r),
+		},
+		Data: map[string]string{
+			"run.json": string(record),
+			"output":   output,
+		},
+	}
+
+	// the record is also annotated by CancelRun, retry when it changed in between
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existing, err := s.K8sClient.GetConfigMap(ctx, r.UserID, cm.Name)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return s.K8sClient.CreateConfigMap(ctx, r.UserID, cm)
+			}
+			return err
+		}
+		existing.Labels = cm.Labels
+		existing.Data = cm.Data
+		return s.K8sClient.UpdateConfigMap(ctx, r.UserID, existing)
+	})
+}
+
+// runFromConfigMap decodes a run record
+func runFromConfigMap(cm *corev1.ConfigMap) (*run, error) {
+	r := &run{}
+	if err := json.Unmarshal([]byte(cm.Data["run.json"]), r); err != nil {
+		return nil, fmt.Errorf("invalid run record %s: %v", cm.Name, err)
+	}
+	r.Output = cm.Data["output"]
+	r.CancelledBy = cm.Annotations["cancelled-by"]
+	return r, nil
+}
+
+// getRun loads a run record
+func (s *ExecutorService) getRun(ctx context.Context, userId, project, runId string) (*run, error) {
+	cm, err := s.K8sClient.GetConfigMap(ctx, userId, runConfigMapName(project, runId))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, fmt.Errorf("run %s not found", runId)
+		}
+		return nil, fmt.Errorf("failed to get run: %v", err)
+	}
+	return runFromConfigMap(cm)
+}
+
+// listRuns loads the run records matching the label selector, newest first
+func (s *ExecutorService) listRuns(ctx context.Context, namespace, labelSelector string) ([]*run, error) {
+	cms, err := s.K8sClient.ListConfigMaps(ctx, namespace, "app=terraform-executor,component=run,"+labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %v", err)
+	}
+	runs := make([]*run, 0, len(cms.Items))
+	for i := range cms.Items {
+		r, err := runFromConfigMap(&cms.Items[i])
+		if err != nil {
+			fmt.Printf("⚠️ Skipping run: %v\n", err)
+			continue
+		}
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].CreatedAt.After(runs[j].CreatedAt)
+	})
+	return runs, nil
+}
+
+// prepareRun checks everything a run needs before its Job can be created
+func (s *ExecutorService) prepareRun(ctx context.Context, r *run) error {
+	if r.offline() {
+		if err := s.ensureNamespace(ctx, r.UserID); err != nil {
+			return fmt.Errorf("namespace error: %w", err)
+		}
+		// the plugin cache lets validation skip the provider downloads
+		if err := s.ensurePVC(ctx, r.UserID); err != nil {
+			return fmt.Errorf("PVC error: %w", err)
+		}
+		return nil
+	}
+	if err := s.ensureResources(ctx, r.UserID); err != nil {
+		return err
+	}
+
+	switch {
+	case r.Type == "plan":
+		// the configuration the plan is created from is hashed when its Job is created
+		r.PlanID = r.ID
+	case r.Type == "apply" && r.PlanID != "":
+		// a stale plan is rejected before it waits in the queue, and again when its Job is created
+		hash, err := s.currentConfigHash(ctx, r.UserID, r.Project)
+		if err != nil {
+			return err
+		}
+		if err := s.verifyPlan(ctx, r.UserID, r.Project, r.PlanID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// submitRun prepares and queues a run, it starts as soon as the project queue and the limits allow it
+func (s *ExecutorService) submitRun(ctx context.Context, r *run) error {
+	if err := s.prepareRun(ctx, r); err != nil {
+		return err
+	}
+	if err := s.saveRun(ctx, r); err != nil {
+		return fmt.Errorf("failed to save run: %v", err)
+	}
+	if err := s.schedule(s.ctx); err != nil {
+		// the queue loop retries, the run stays queued
+		fmt.Printf("⚠️ Failed to schedule runs: %v\n", err)
+	}
+	return nil
+}
+
+// goRun monitors the started run in the background and tracks it until it finished
+func (s *ExecutorService) goRun(r *run) {
+	done := make(chan struct{})
+	s.runsMu.Lock()
+	s.runDone[r.ID] = done
+	s.runsMu.Unlock()
+
+	go func() {
+		defer func() {
+			s.runsMu.Lock()
+			delete(s.runDone, r.ID)
+			s.runsMu.Unlock()
+			close(done)
+		}()
+		s.executeRun(r)
+
+		// the finished run may unblock queued runs
+		if err := s.schedule(s.ctx); err != nil {
+			fmt.Printf("⚠️ Failed to schedule runs: %v\n", err)
+		}
+	}()
+}
+
+// executeRun waits for the runner Job of a started run and records the result
+func (s *ExecutorService) executeRun(r *run) {
+	ctx := s.ctx
+
+	if _, err := s.K8sClient.GetJob(ctx, r.UserID, r.JobName); k8serrors.IsNotFound(err) {
+		// resumed run whose Job was already cleaned up
+		s.finishRun(ctx, r, runPhaseFailed, fmt.Sprintf("runner job %s no longer exists", r.JobName))
+		return
+	}
+
+	output, err := s.waitForJobAndGetLogs(ctx, r)
+	r.Output = renderUIOutput(output)
+	r.Diagnostics = s.collectDiagnostics(r.Project, output)
+	s.archiveRun(ctx, r)
+	var failure *podFailure
+	if errors.As(err, &failure) {
+		// the pod won't complete on its own, free its resources right away
+		r.Failure = failure.Reason
+		if err := s.K8sClient.DeleteJob(ctx, r.UserID, r.JobName); err != nil && !k8serrors.IsNotFound(err) {
+			fmt.Printf("⚠️ Failed to delete job %s: %v\n", r.JobName, err)
+		}
+		s.finishRun(ctx, r, runPhaseFailed, failure.Message)
+		return
+	}
+	if err != nil {
+		s.finishRun(ctx, r, runPhaseFailed, fmt.Sprintf("job execution failed: %v", err))
+		return
+	}
+
+	if pod, err := s.K8sClient.GetJobPod(ctx, r.UserID, r.JobName); err == nil {
+		r.ExitCode = runnerExitCode(pod)
+	}
+	job, err := s.K8sClient.GetJob(ctx, r.UserID, r.JobName)
+	if err != nil {
+		s.finishRun(ctx, r, runPhaseFailed, fmt.Sprintf("failed to get job status: %v", err))
+		return
+	}
+	if job.Status.Succeeded == 0 {
+		s.finishRun(ctx, r, runPhaseFailed, fmt.Sprintf("terraform %s failed with exit code %d", r.Type, r.ExitCode))
+		return
+	}
+
+	if r.Type == "plan" {
+		if err := s.savePlanMetadata(ctx, r.UserID, r.Project, r.PlanID, r.RequestID, r.ConfigHash); err != nil {
+			s.finishRun(ctx, r, runPhaseFailed, fmt.Sprintf("failed to save plan: %v", err))
+			return
+		}
+	}
+	s.finishRun(ctx, r, runPhaseSucceeded, "")
+}
+
+// finishRun moves the run to a final phase and persists it
+func (s *ExecutorService) finishRun(ctx context.Context, r *run, phase, errMsg string) {
+	// a cancellation recorded by CancelRun wins over the outcome seen by the monitor
+	if stored, err := s.getRun(ctx, r.UserID, r.Project, r.ID); err == nil {
+		if stored.CancelledBy != "" {
+			r.CancelledBy = stored.CancelledBy
+			phase = runPhaseCancelled
+			errMsg = fmt.Sprintf("run cancelled by %s", stored.CancelledBy)
+		}
+		if r.Output == "" {
+			r.Output = stored.Output
+		}
+	}
+	r.Phase = phase
+	r.Error = errMsg
+	r.FinishedAt = time.Now().UTC()
+	if err := s.saveRun(ctx, r); err != nil {
+		fmt.Printf("⚠️ Failed to save run %s: %v\n", r.ID, err)
+	}
+	s.pruneRuns(ctx, r.UserID, r.Project)
+}
+
+// deleteRun deletes the record of a run with its logs and artifacts
+func (s *ExecutorService) deleteRun(ctx context.Context, r *run) error {
+	keys, err := s.AWSClient.ListObjects(ctx, s.Bucket, runPrefix(r.UserID, r.Project, r.ID)+"/")
+	if err != nil {
+		return fmt.Errorf("failed to list the artifacts of run %s: %v", r.ID, err)
+	}
+	for _, key := range keys {
+		if err := s.AWSClient.DeleteObject(ctx, s.Bucket, key); err != nil {
+			return fmt.Errorf("failed to delete the artifacts of run %s: %v", r.ID, err)
+		}
+	}
+	if err := s.K8sClient.DeleteConfigMap(ctx, r.UserID, runConfigMapName(r.Project, r.ID)); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete run %s: %v", r.ID, err)
+	}
+	return nil
+}
+
+// pruneRuns deletes the finished runs of a project beyond the newest maxRuns
+func (s *ExecutorService) pruneRuns(ctx context.Context, namespace, project string) {
+	runs, err := s.listRuns(ctx, namespace, "project="+project+",phase in (succeeded,failed,cancelled)")
+	if err != nil {
+		fmt.Printf("⚠️ Failed to prune the runs of project %s: %v\n", project, err)
+		return
+	}
+	for i := maxRuns; i < len(runs); i++ {
+		if err := s.deleteRun(ctx, runs[i]); err != nil {
+			fmt.Printf("⚠️ Failed to prune run %s of project %s: %v\n", runs[i].ID, project, err)
+		}
+	}
+}
+
+// deleteRuns deletes the queued and finished runs of a project. Runs still executing keep
+// their record, it is saved again when they finish.
+func (s *ExecutorService) deleteRuns(ctx context.Context, namespace, project string) error {
+	runs, err := s.listRuns(ctx, namespace, "project="+project+",phase in (queued,succeeded,failed,cancelled)")
+	if err != nil {
+		return err
+	}
+	for _, r := range runs {
+		if err := s.deleteRun(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeRunLog advances the phase of a run based on the runner output
+func (s *ExecutorService) observeRunLog(ctx context.Context, r *run, logs string) {
+	if r.Phase != runPhaseInitializing || !strings.Contains(logs, "has been successfully initialized") {
+		return
+	}
+	r.Phase = runPhasePlanning
+	if r.mutating() {
+		r.Phase = runPhaseApplying
+	}
+	if err := s.saveRun(ctx, r); err != nil {
+		fmt.Printf("⚠️ Failed to save run %s: %v\n", r.ID, err)
+	}
+}
+
+// runnerExitCode returns the exit code of the terminated runner container
+func runnerExitCode(pod *corev1.Pod) int32 {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == "runner" && status.State.Terminated != nil {
+			return status.State.Terminated.ExitCode
+		}
+	}
+	return 0
+}
+
+// waitRun blocks until a run started by this executor finished and returns its final record
+func (s *ExecutorService) waitRun(ctx context.Context, r *run) (*run, error) {
+	s.runsMu.Lock()
+	done, ok := s.runDone[r.ID]
+	s.runsMu.Unlock()
+
+	if ok {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return s.getRun(ctx, r.UserID, r.Project, r.ID)
+	}
+
+	// run is executed by another process, poll its record
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		current, err := s.getRun(ctx, r.UserID, r.Project, r.ID)
+		if err != nil || current.finished() {
+			return current, err
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// runAndWait submits a run and blocks until it finished.
+// The run is cancelled when the caller goes away so its pod doesn't keep running orphaned.
+func (s *ExecutorService) runAndWait(ctx context.Context, r *run) (*run, error) {
+	if err := s.submitRun(ctx, r); err != nil {
+		return nil, err
+	}
+	userId, project, runId := r.UserID, r.Project, r.ID
+	finished, err := s.waitRun(ctx, r)
+	if err != nil && ctx.Err() != nil {
+		go func() {
+			if err := s.cancelRun(s.ctx, userId, project, runId, "client disconnect", defaultCancelGracePeriod); err != nil {
+				fmt.Printf("⚠️ Failed to cancel run %s: %v\n", runId, err)
+			}
+		}()
+	}
+	return finished, err
+}
+
+// resumeRuns picks up runs left unfinished by a previous executor process
+func (s *ExecutorService) resumeRuns() {
+	runs, err := s.listRuns(s.ctx, "", "phase notin (succeeded,failed,cancelled)")
+	if err != nil {
+		fmt.Printf("⚠️ Failed to resume runs: %v\n", err)
+		return
+	}
+	for _, r := range runs {
+		if r.Phase == runPhaseQueued {
+			// queued runs are started by the queue loop
+			continue
+		}
+		fmt.Printf("Resuming run %s in namespace %s\n", r.ID, r.UserID)
+		s.goRun(r)
+	}
+}
+
+// StartRun starts a Terraform operation in the background and returns its run ID.
+func (s *ExecutorService) StartRun(ctx context.Context, req *pb.StartRunRequest) (*pb.StartRunResponse, error) {
+	runType, ok := runTypes[req.Type]
+	if !ok {
+		return &pb.StartRunResponse{Success: false, Error: fmt.Sprintf("unsupported run type %s", req.Type)}, nil
+	}
+	if req.PlanId != "" && req.Type != pb.Run_APPLY {
+		return &pb.StartRunResponse{Success: false, Error: "plan_id can only be used with apply runs"}, nil
+	}
+
+	r := newRun(req.UserId, req.Project, req.RequestId, runType)
+	r.PlanID = req.PlanId
+	if err := s.submitRun(ctx, r); err != nil {
+		return &pb.StartRunResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.StartRunResponse{Success: true, RunId: r.ID}, nil
+}
+
+// GetRun returns the status and output of a run.
+func (s *ExecutorService) GetRun(ctx context.Context, req *pb.GetRunRequest) (*pb.GetRunResponse, error) {
+	r, err := s.getRun(ctx, req.UserId, req.Project, req.RunId)
+	if err != nil {
+		return &pb.GetRunResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.GetRunResponse{Success: true, Run: r.toProto(true)}, nil
+}
+
+// ListRuns returns the runs of a project, newest first.
+func (s *ExecutorService) ListRuns(ctx context.Context, req *pb.ListRunsRequest) (*pb.ListRunsResponse, error) {
+	runs, err := s.listRuns(ctx, req.UserId, fmt.Sprintf("project=%s", req.Project))
+	if err != nil {
+		return &pb.ListRunsResponse{Success: false, Error: err.Error()}, nil
+	}
+	resp := &pb.ListRunsResponse{Success: true}
+	for _, r := range runs {
+		resp.Runs = append(resp.Runs, r.toProto(false))
+	}
+	return resp, nil
+}
@@ -0,0 +1,223 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"terraform-executor/pkg/utils"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Engines a project can run with
+const (
+	engineTerraform = "terraform"
+	engineTofu      = "tofu"
+)
+
+// engineImages are the runner images of the engines, tagged with the version
+var engineImages = map[string]string{
+	engineTerraform: "hashicorp/terraform",
+	engineTofu:      "ghcr.io/opentofu/opentofu",
+}
+
+// defaultEngineVersions are the versions projects may pin when the allowlist isn't configured
+var defaultEngineVersions = map[string][]string{
+	engineTerraform: {"1.5.7", "1.6.6", "1.7.5", "1.8.5", "1.9.8", "1.10.5", "1.11.4"},
+	engineTofu:      {"1.6.3", "1.7.8", "1.8.10", "1.9.1"},
+}
+
+var imageDigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// projectRuntime is the engine a project runs with. The version is empty only for defaultRuntime,
+// which runs the latest image.
+type projectRuntime struct {
+	Engine      string
+	Version     string
+	ImageDigest string
+}
+
+// defaultRuntime is used by projects without a runtime setting
+var defaultRuntime = projectRuntime{Engine: engineTerraform}
+
+// image returns the runner image of the runtime, pinned to the digest if set
+func (rt projectRuntime) image() string {
+	tag := rt.Version
+	if tag == "" {
+		tag = "latest"
+	}
+	image := fmt.Sprintf("%s:%s", engineImages[rt.Engine], tag)
+	if rt.ImageDigest != "" {
+		image += "@" + rt.ImageDigest
+	}
+	return image
+}
+
+// engineVersionsFromEnv reads the version allowlist from TERRAFORM_VERSIONS and TOFU_VERSIONS ("1.9.8,1.10.5")
+func engineVersionsFromEnv() map[string][]string {
+	versions := make(map[string][]string)
+	for engine, env := range map[string]string{engineTerraform: "TERRAFORM_VERSIONS", engineTofu: "TOFU_VERSIONS"} {
+		versions[engine] = defaultEngineVersions[engine]
+		if v := os.Getenv(env); v != "" {
+			versions[engine] = nil
+			for _, version := range strings.Split(v, ",") {
+				versions[engine] = append(versions[engine], strings.TrimSpace(version))
+			}
+		}
+	}
+	return versions
+}
+
+// validateRuntime checks the engine, the version against the allowlist and the digest format.
+// A runtime always pins a version, the latest image is left to projects without a runtime.
+func (s *ExecutorService) validateRuntime(rt projectRuntime) error {
+	if _, ok := engineImages[rt.Engine]; !ok {
+		return fmt.Errorf("unknown engine %q, expected %q or %q", rt.Engine, engineTerraform, engineTofu)
+	}
+	if rt.Version == "" {
+		return fmt.Errorf("%s version is required, allowed versions: %s", rt.Engine, strings.Join(s.engineVersions[rt.Engine], ", "))
+	}
+	if !slices.Contains(s.engineVersions[rt.Engine], rt.Version) {
+		return fmt.Errorf("%s version %q is not allowed, allowed versions: %s", rt.Engine, rt.Version, strings.Join(s.engineVersions[rt.Engine], ", "))
+	}
+	if rt.ImageDigest != "" && !imageDigestRegexp.MatchString(rt.ImageDigest) {
+		return fmt.Errorf("invalid image digest %q, expected sha256:<hex>", rt.ImageDigest)
+	}
+	return nil
+}
+
+// runtimeConfigMapName returns the name of the ConfigMap holding the runtime of a project
+func runtimeConfigMapName(project string) string {
+	return fmt.Sprintf("%s.%s", project, "runtime")
+}
+
+// getRuntime returns the runtime of a project, or the default runtime if it isn't set
+func (s *ExecutorService) getRuntime(ctx context.Context, namespace, project string) (projectRuntime, error) {
+	cm, err := s.K8sClient.GetConfigMap(ctx, namespace, runtimeConfigMapName(project))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return defaultRuntime, nil
+		}
+		return projectRuntime{}, fmt.Errorf("failed to get runtime: %v", err)
+	}
+	return projectRuntime{
+		Engine:      cm.Data["engine"],
+		Version:     cm.Data["version"],
+		ImageDigest: cm.Data["image_digest"],
+	}, nil
+}
+
+// clearRuntime removes the runtime setting of a project
+func (s *ExecutorService) clearRuntime(ctx context.Context, namespace, project string) error {
+	if err := s.K8sClient.DeleteConfigMap(ctx, namespace, runtimeConfigMapName(project)); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete runtime ConfigMap: %v", err)
+	}
+	return nil
+}
+
+// requiredVersion returns the required_version constraint matching the runtime
+func (rt projectRuntime) requiredVersion() string {
+	if rt.Version == "" {
+		return ""
+	}
+	return "= " + rt.Version
+}
+
+// syncRequiredVersion keeps the required_version of versions.tf in sync with the runtime
+func (s *ExecutorService) syncRequiredVersion(ctx context.Context, namespace, project string, rt projectRuntime) error {
+	versions, err := s.store.Get(ctx, namespace, project, "versions.tf")
+	if err != nil {
+		return err
+	}
+	if !versions.Exists {
+		// AddProviders renders it when the providers are added
+		return nil
+	}
+	return s.store.Put(ctx, namespace, project, "versions.tf", versions, utils.SetRequiredVersion(versions.Content, rt.requiredVersion()))
+}
+
+// SetRuntime sets the engine, version and image digest the runs of a project execute with.
+func (s *ExecutorService) SetRuntime(ctx context.Context, req *pb.SetRuntimeRequest) (*pb.SetRuntimeResponse, error) {
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.SetRuntimeResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	rt := projectRuntime{
+		Engine:      req.Engine,
+		Version:     req.Version,
+		ImageDigest: req.ImageDigest,
+	}
+	if rt.Engine == "" {
+		rt.Engine = engineTerraform
+	}
+	if err := s.validateRuntime(rt); err != nil {
+		return &pb.SetRuntimeResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: runtimeConfigMapName(req.Project),
+		},
+		Data: map[string]string{
+			"engine":       rt.Engine,
+			"version":      rt.Version,
+			"image_digest": rt.ImageDigest,
+		},
+	}
+	binding, err := s.getGitBinding(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.SetRuntimeResponse{Success: false, Error: err.Error()}, nil
+	}
+	// the runtime isn't one of the project files, it may be set for a project bound to git
+	revision, err := s.mutateRevision(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "SetRuntime"}, func() error {
+		existingCm, err := s.K8sClient.GetConfigMap(ctx, req.UserId, cm.Name)
+		if err != nil {
+			if !k8serrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get ConfigMap: %w", err)
+			}
+			if err := s.K8sClient.CreateConfigMap(ctx, req.UserId, cm); err != nil {
+				return fmt.Errorf("failed to create ConfigMap: %w", err)
+			}
+		} else {
+			existingCm.Data = cm.Data
+			if err := s.K8sClient.UpdateConfigMap(ctx, req.UserId, existingCm); err != nil {
+				return fmt.Errorf("failed to update ConfigMap: %w", err)
+			}
+		}
+		if binding != nil {
+			// versions.tf is synced from the repository, it pins the version itself
+			return nil
+		}
+		return s.syncRequiredVersion(ctx, req.UserId, req.Project, rt)
+	})
+	if err != nil {
+		return &pb.SetRuntimeResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	return &pb.SetRuntimeResponse{Success: true, Image: rt.image(), Revision: revision}, nil
+}
+
+// GetRuntime returns the runtime of a project and the versions it may pin.
+func (s *ExecutorService) GetRuntime(ctx context.Context, req *pb.GetRuntimeRequest) (*pb.GetRuntimeResponse, error) {
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.GetRuntimeResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	rt, err := s.getRuntime(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.GetRuntimeResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.GetRuntimeResponse{
+		Success:         true,
+		Engine:          rt.Engine,
+		Version:         rt.Version,
+		ImageDigest:     rt.ImageDigest,
+		Image:           rt.image(),
+		AllowedVersions: s.engineVersions[rt.Engine],
+	}, nil
+}
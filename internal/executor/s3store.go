@@ -0,0 +1,259 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"terraform-executor/internal/awsclient"
+	"terraform-executor/internal/k8s"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const storeS3 = "s3"
+
+// s3Store keeps the files of a project in the state bucket under <user>/<project>/config/files/<path>
+// and the files of each revision under <user>/<project>/config/snapshots/<hash>/<path>. The runner
+// fetches the snapshot of its revision with the credentials of the user in an init container.
+type s3Store struct {
+	aws    *awsclient.AWSClient
+	bucket string
+	region string
+
+	// legacy holds the files of projects created with the configmap store, they are moved
+	// to the bucket when the project is accessed first
+	legacy   *configMapStore
+	migrated sync.Map // namespace/project
+}
+
+func newS3Store(aws *awsclient.AWSClient, k8sClient *k8s.K8sClient, bucket, region string) *s3Store {
+	return &s3Store{
+		aws:    aws,
+		bucket: bucket,
+		region: region,
+		legacy: &configMapStore{k8s: k8sClient},
+	}
+}
+
+func (st *s3Store) Name() string {
+	return storeS3
+}
+
+// configPrefix returns the S3 prefix where the configuration of a project is stored
+func configPrefix(userId, project string) string {
+	return fmt.Sprintf("%s/%s/config/", userId, project)
+}
+
+func filesPrefix(userId, project string) string {
+	return configPrefix(userId, project) + "files/"
+}
+
+func snapshotPrefix(userId, project, hash string) string {
+	return configPrefix(userId, project) + "snapshots/" + hash + "/"
+}
+
+// migrate moves the files of a project from its ConfigMaps to the bucket
+func (st *s3Store) migrate(ctx context.Context, namespace, project string) error {
+	key := namespace + "/" + project
+	if _, ok := st.migrated.Load(key); ok {
+		return nil
+	}
+	files, err := st.legacy.List(ctx, namespace, project)
+	if err != nil {
+		return err
+	}
+	for p, content := range files {
+		// a file written to the bucket by another executor is newer than its ConfigMap
+		err := st.aws.PutObjectIf(ctx, st.bucket, filesPrefix(namespace, project)+p, []byte(content), "")
+		if err != nil && !errors.Is(err, awsclient.ErrPreconditionFailed) {
+			return fmt.Errorf("failed to migrate %s of project %s: %w", p, project, err)
+		}
+	}
+	if len(files) > 0 {
+		if err := st.legacy.Clear(ctx, namespace, project); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Migrated %d files of project %s/%s to S3\n", len(files), namespace, project)
+	}
+	st.migrated.Store(key, true)
+	return nil
+}
+
+// readPrefix returns the files stored under a prefix by their path relative to it
+func (st *s3Store) readPrefix(ctx context.Context, prefix string) (map[string]string, error) {
+	keys, err := st.aws.ListObjects(ctx, st.bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string, len(keys))
+	for _, key := range keys {
+		p := strings.TrimPrefix(key, prefix)
+		if validateFilePath(p) != nil {
+			continue
+		}
+		content, err := st.aws.GetObject(ctx, st.bucket, key)
+		if err != nil {
+			if errors.Is(err, awsclient.ErrObjectNotFound) {
+				// deleted since it was listed
+				continue
+			}
+			return nil, err
+		}
+		files[p] = string(content)
+	}
+	return files, nil
+}
+
+// deletePrefix deletes the objects under a prefix
+func (st *s3Store) deletePrefix(ctx context.Context, prefix string) error {
+	keys, err := st.aws.ListObjects(ctx, st.bucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := st.aws.DeleteObject(ctx, st.bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *s3Store) ConfigMap(project, p string) string {
+	return ""
+}
+
+func (st *s3Store) Get(ctx context.Context, namespace, project, p string) (*storedFile, error) {
+	if err := st.migrate(ctx, namespace, project); err != nil {
+		return nil, err
+	}
+	content, etag, err := st.aws.GetObjectETag(ctx, st.bucket, filesPrefix(namespace, project)+p)
+	if err != nil {
+		if errors.Is(err, awsclient.ErrObjectNotFound) {
+			return &storedFile{}, nil
+		}
+		return nil, err
+	}
+	return &storedFile{Content: string(content), Exists: true, Version: etag}, nil
+}
+
+func (st *s3Store) Put(ctx context.Context, namespace, project, p string, prev *storedFile, content string) error {
+	if err := st.migrate(ctx, namespace, project); err != nil {
+		return err
+	}
+	err := st.aws.PutObjectIf(ctx, st.bucket, filesPrefix(namespace, project)+p, []byte(content), prev.Version)
+	if errors.Is(err, awsclient.ErrPreconditionFailed) {
+		return fmt.Errorf("%w: %v", errStaleFile, err)
+	}
+	return err
+}
+
+func (st *s3Store) Delete(ctx context.Context, namespace, project, p string) error {
+	if err := st.migrate(ctx, namespace, project); err != nil {
+		return err
+	}
+	return st.aws.DeleteObject(ctx, st.bucket, filesPrefix(namespace, project)+p)
+}
+
+func (st *s3Store) List(ctx context.Context, namespace, project string) (map[string]string, error) {
+	if err := st.migrate(ctx, namespace, project); err != nil {
+		return nil, err
+	}
+	return st.readPrefix(ctx, filesPrefix(namespace, project))
+}
+
+func (st *s3Store) Replace(ctx context.Context, namespace, project string, files map[string]string) error {
+	if err := st.migrate(ctx, namespace, project); err != nil {
+		return err
+	}
+	prefix := filesPrefix(namespace, project)
+	keys, err := st.aws.ListObjects(ctx, st.bucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, p := range sortedPaths(files) {
+		if err := st.aws.PutObject(ctx, st.bucket, prefix+p, []byte(files[p])); err != nil {
+			return err
+		}
+	}
+	for _, key := range keys {
+		if _, ok := files[strings.TrimPrefix(key, prefix)]; ok {
+			continue
+		}
+		if err := st.aws.DeleteObject(ctx, st.bucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear deletes the files and the snapshots of the revisions, including those left in ConfigMaps
+func (st *s3Store) Clear(ctx context.Context, namespace, project string) error {
+	if err := st.legacy.Clear(ctx, namespace, project); err != nil {
+		return err
+	}
+	if err := st.deletePrefix(ctx, configPrefix(namespace, project)); err != nil {
+		return err
+	}
+	st.migrated.Delete(namespace + "/" + project)
+	return nil
+}
+
+// Snapshot uploads the files of a revision, snapshots are addressed by the hash of their files
+// so revisions with the same files share theirs
+func (st *s3Store) Snapshot(ctx context.Context, namespace, project, hash string, files map[string]string) (map[string]string, error) {
+	prefix := snapshotPrefix(namespace, project, hash)
+	for _, p := range sortedPaths(files) {
+		if err := st.aws.PutObject(ctx, st.bucket, prefix+p, []byte(files[p])); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (st *s3Store) ReadSnapshot(ctx context.Context, namespace, project, hash string) (map[string]string, error) {
+	return st.readPrefix(ctx, snapshotPrefix(namespace, project, hash))
+}
+
+func (st *s3Store) DeleteSnapshot(ctx context.Context, namespace, project, hash string) error {
+	return st.deletePrefix(ctx, snapshotPrefix(namespace, project, hash))
+}
+
+// Mount copies the snapshot of the revision to the workspace volume in an init container, the runner
+// copies it to its working directory. Revisions recorded before the migration hold their files inline.
+func (st *s3Store) Mount(ctx context.Context, namespace, project string, rev *revision, pod *runnerPod) error {
+	if rev.Store != storeS3 {
+		mountRevision(project, rev, pod)
+		return nil
+	}
+	pod.InitContainers = append(pod.InitContainers, corev1.Container{
+		Name:    "config",
+		Image:   awsCLIImage,
+		Command: []string{"/bin/sh", "-c"},
+		Args:    []string{"mkdir -p /workspace/config && aws s3 cp --recursive --no-progress \"$CONFIG_URL\" /workspace/config/"},
+		Env: []corev1.EnvVar{
+			{Name: "AWS_PROFILE", Value: "tfstate"},
+			{Name: "AWS_REGION", Value: st.region},
+			{Name: "CONFIG_URL", Value: fmt.Sprintf("s3://%s/%s", st.bucket, snapshotPrefix(namespace, project, rev.Hash))},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "aws-creds", MountPath: "/root/.aws"},
+			{Name: "workspace", MountPath: "/workspace"},
+		},
+	})
+	pod.Setup = "cp -R /workspace/config/. ."
+	return nil
+}
+
+// Limits are bounded by the gRPC message size of PutFile and the time the runner takes to fetch the files
+func (st *s3Store) Limits() fileLimits {
+	return fileLimits{
+		File:  1 << 20,
+		Total: 16 << 20,
+	}
+}
+
+func (st *s3Store) Credentials() bool {
+	return true
+}
@@ -0,0 +1,351 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Reasons a queued run is waiting
+const (
+	waitProject = "waiting for earlier runs of the project"
+	waitGlobal  = "cluster-wide job limit reached"
+	waitUser    = "job limit of the user reached"
+	waitType    = "job limit of the operation type reached"
+)
+
+// schedulerLimits configures the admission of runner Jobs, zero means unlimited
+type schedulerLimits struct {
+	MaxJobs        int
+	MaxJobsPerUser int
+	MaxJobsPerType map[string]int
+}
+
+// limitsFromEnv reads the scheduler limits from MAX_CONCURRENT_JOBS,
+// MAX_CONCURRENT_JOBS_PER_USER and MAX_CONCURRENT_JOBS_PER_TYPE ("apply=2,plan=10")
+func limitsFromEnv() (schedulerLimits, error) {
+	limits := schedulerLimits{MaxJobsPerType: make(map[string]int)}
+	var err error
+	if v := os.Getenv("MAX_CONCURRENT_JOBS"); v != "" {
+		if limits.MaxJobs, err = strconv.Atoi(v); err != nil {
+			return limits, fmt.Errorf("invalid MAX_CONCURRENT_JOBS: %v", err)
+		}
+	}
+	if v := os.Getenv("MAX_CONCURRENT_JOBS_PER_USER"); v != "" {
+		if limits.MaxJobsPerUser, err = strconv.Atoi(v); err != nil {
+			return limits, fmt.Errorf("invalid MAX_CONCURRENT_JOBS_PER_USER: %v", err)
+		}
+	}
+	if v := os.Getenv("MAX_CONCURRENT_JOBS_PER_TYPE"); v != "" {
+		for _, pair := range strings.Split(v, ",") {
+			runType, max, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				return limits, fmt.Errorf("invalid MAX_CONCURRENT_JOBS_PER_TYPE entry %q", pair)
+			}
+			if limits.MaxJobsPerType[runType], err = strconv.Atoi(max); err != nil {
+				return limits, fmt.Errorf("invalid MAX_CONCURRENT_JOBS_PER_TYPE entry %q: %v", pair, err)
+			}
+		}
+	}
+	return limits, nil
+}
+
+// scheduleState is the outcome of a scheduling pass
+type scheduleState struct {
+	active  int
+	perUser map[string]int
+	perType map[string]int
+	start   []*run            // runs admitted by the pass
+	waiting []*run            // runs left queued, in the order they are considered next
+	reasons map[string]string // why a waiting run can't start
+	cursor  string            // user a run was admitted for last
+}
+
+// admit returns why the limits don't admit the run, or an empty string when they do
+func (l schedulerLimits) admit(st *scheduleState, r *run) string {
+	switch {
+	case l.MaxJobs > 0 && st.active >= l.MaxJobs:
+		return waitGlobal
+	case l.MaxJobsPerUser > 0 && st.perUser[r.UserID] >= l.MaxJobsPerUser:
+		return waitUser
+	case l.MaxJobsPerType[r.Type] > 0 && st.perType[r.Type] >= l.MaxJobsPerType[r.Type]:
+		return waitType
+	}
+	return ""
+}
+
+// planSchedule decides which queued runs start. runs are the unfinished runs,
+// oldest first. The runs each project queue allows are admitted round-robin
+// across users, starting after the user served last, as long as the limits allow it.
+func planSchedule(limits schedulerLimits, runs []*run, activeJobs []*batchv1.Job, cursor string) *scheduleState {
+	st := &scheduleState{
+		perUser: make(map[string]int),
+		perType: make(map[string]int),
+		reasons: make(map[string]string),
+		cursor:  cursor,
+	}
+	activeRuns := make(map[string]bool)
+	for _, job := range activeJobs {
+		st.active++
+		st.perUser[job.Labels["user"]]++
+		st.perType[job.Labels["type"]]++
+		activeRuns[job.Labels["run-id"]] = true
+	}
+
+	// Apply the rules of each project queue first
+	type projectQueue struct{ active, queued []*run }
+	projects := make(map[string]*projectQueue)
+	var order []string
+	for _, r := range runs {
+		key := r.UserID + "/" + r.Project
+		q, ok := projects[key]
+		if !ok {
+			q = &projectQueue{}
+			projects[key] = q
+			order = append(order, key)
+		}
+		if r.Phase == runPhaseQueued {
+			q.queued = append(q.queued, r)
+		} else if activeRuns[r.ID] {
+			q.active = append(q.active, r)
+		}
+	}
+	candidates := make(map[string][]*run)
+	isCandidate := make(map[string]bool)
+	for _, key := range order {
+		for _, r := range runnable(projects[key].active, projects[key].queued) {
+			candidates[r.UserID] = append(candidates[r.UserID], r)
+			isCandidate[r.ID] = true
+		}
+	}
+
+	// Serve users round-robin, starting after the cursor
+	var users []string
+	for u, c := range candidates {
+		users = append(users, u)
+		sort.SliceStable(c, func(i, j int) bool { return c[i].CreatedAt.Before(c[j].CreatedAt) })
+	}
+	sort.Strings(users)
+	first := sort.SearchStrings(users, cursor)
+	if first < len(users) && users[first] == cursor {
+		first++
+	}
+	users = append(users[first:], users[:first]...)
+
+	for len(users) > 0 {
+		var next []string
+		for _, u := range users {
+			r := candidates[u][0]
+			candidates[u] = candidates[u][1:]
+			if reason := limits.admit(st, r); reason != "" {
+				st.reasons[r.ID] = reason
+				st.waiting = append(st.waiting, r)
+				if reason != waitType {
+					// none of the other runs of the user can start either
+					for _, rest := range candidates[u] {
+						st.reasons[rest.ID] = reason
+						st.waiting = append(st.waiting, rest)
+					}
+					candidates[u] = nil
+				}
+			} else {
+				st.start = append(st.start, r)
+				st.active++
+				st.perUser[r.UserID]++
+				st.perType[r.Type]++
+				st.cursor = u
+			}
+			if len(candidates[u]) > 0 {
+				next = append(next, u)
+			}
+		}
+		users = next
+	}
+
+	for _, r := range runs {
+		if r.Phase == runPhaseQueued && !isCandidate[r.ID] {
+			st.reasons[r.ID] = waitProject
+			st.waiting = append(st.waiting, r)
+		}
+	}
+	return st
+}
+
+// loadSchedule returns the unfinished runs, oldest first, and the active runner Jobs.
+// Started runs whose Job disappeared without being recorded as finished are failed.
+func (s *ExecutorService) loadSchedule(ctx context.Context) ([]*run, []*batchv1.Job, error) {
+	runs, err := s.listRuns(ctx, "", "phase notin (succeeded,failed,cancelled)")
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, err := s.K8sClient.ListJobs(ctx, "", "app=terraform-executor")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list jobs: %v", err)
+	}
+
+	var activeJobs []*batchv1.Job
+	jobRuns := make(map[string]bool)
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		jobRuns[job.Labels["run-id"]] = true
+		if jobActive(job) {
+			activeJobs = append(activeJobs, job)
+		}
+	}
+
+	// listRuns returns the newest run first
+	var unfinished []*run
+	for i := len(runs) - 1; i >= 0; i-- {
+		r := runs[i]
+		if r.Phase != runPhaseQueued && !jobRuns[r.ID] {
+			s.runsMu.Lock()
+			_, monitored := s.runDone[r.ID]
+			s.runsMu.Unlock()
+			if !monitored {
+				s.finishRun(ctx, r, runPhaseFailed, fmt.Sprintf("runner job %s no longer exists", r.JobName))
+				continue
+			}
+		}
+		unfinished = append(unfinished, r)
+	}
+	return unfinished, activeJobs, nil
+}
+
+// prepareSchedule prepares the runs a scheduling pass would start, keyed by their ID. It runs
+// without the lock, nothing is prepared while another executor holds it.
+func (s *ExecutorService) prepareSchedule(ctx context.Context) (map[string]*run, error) {
+	cursor := ""
+	if lock, err := s.K8sClient.GetConfigMap(ctx, s.Namespace, schedulerLockName); err == nil {
+		acquiredAt, err := time.Parse(time.RFC3339, lock.Annotations["acquired-at"])
+		if lock.Annotations["holder"] != "" && err == nil && time.Since(acquiredAt) < lockTTL {
+			return nil, nil
+		}
+		cursor = lock.Annotations["last-user"]
+	} else if !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get lock %s: %v", schedulerLockName, err)
+	}
+
+	runs, activeJobs, err := s.loadSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prepared := make(map[string]*run)
+	for _, r := range planSchedule(s.limits, runs, activeJobs, cursor).start {
+		if err := s.prepareRunJob(ctx, r); err != nil {
+			s.finishRun(ctx, r, runPhaseFailed, err.Error())
+			continue
+		}
+		prepared[r.ID] = r
+	}
+	return prepared, nil
+}
+
+// schedule starts the queued runs the project queues and the limits allow
+// and updates the queue position of the others
+func (s *ExecutorService) schedule(ctx context.Context) error {
+	prepared, err := s.prepareSchedule(ctx)
+	if err != nil || prepared == nil {
+		return err
+	}
+
+	// the lock is only held to admit the prepared runs, bind them to their state and files and create their Jobs
+	lock, err := s.acquireLock(ctx, schedulerLockName)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		// the executor holding the lock schedules, the queue loop retries otherwise
+		return nil
+	}
+	defer s.releaseLock(ctx, lock)
+
+	runs, activeJobs, err := s.loadSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	st := planSchedule(s.limits, runs, activeJobs, lock.Annotations["last-user"])
+
+	started := make(map[string]bool)
+	for _, r := range st.start {
+		p, ok := prepared[r.ID]
+		if !ok {
+			// admitted since the runs were prepared, it starts with the next pass
+			continue
+		}
+		started[r.ID] = true
+		// the next pass serves the users after the one a run started for last
+		lock.Annotations["last-user"] = r.UserID
+		if err := s.startRunJob(ctx, p); err != nil {
+			s.finishRun(ctx, p, runPhaseFailed, err.Error())
+			continue
+		}
+		s.goRun(p)
+	}
+
+	positions := make(map[string]int32)
+	for _, r := range runs {
+		if r.Phase != runPhaseQueued || started[r.ID] {
+			continue
+		}
+		key := r.UserID + "/" + r.Project
+		positions[key]++
+		if r.QueuePos != positions[key] || r.QueueReason != st.reasons[r.ID] {
+			r.QueuePos = positions[key]
+			r.QueueReason = st.reasons[r.ID]
+			if err := s.saveRun(ctx, r); err != nil {
+				fmt.Printf("⚠️ Failed to save run %s: %v\n", r.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GetSchedulerState returns the limits, the active runner Jobs and the queued runs.
+func (s *ExecutorService) GetSchedulerState(ctx context.Context, req *pb.GetSchedulerStateRequest) (*pb.GetSchedulerStateResponse, error) {
+	cursor := ""
+	if lock, err := s.K8sClient.GetConfigMap(ctx, s.Namespace, schedulerLockName); err == nil {
+		cursor = lock.Annotations["last-user"]
+	} else if !k8serrors.IsNotFound(err) {
+		return &pb.GetSchedulerStateResponse{Success: false, Error: fmt.Sprintf("failed to get scheduler lock: %v", err)}, nil
+	}
+
+	runs, activeJobs, err := s.loadSchedule(ctx)
+	if err != nil {
+		return &pb.GetSchedulerStateResponse{Success: false, Error: err.Error()}, nil
+	}
+	st := planSchedule(s.limits, runs, activeJobs, cursor)
+
+	resp := &pb.GetSchedulerStateResponse{
+		Success: true,
+		Limits: &pb.SchedulerLimits{
+			MaxJobs:        int32(s.limits.MaxJobs),
+			MaxJobsPerUser: int32(s.limits.MaxJobsPerUser),
+			MaxJobsPerType: make(map[string]int32),
+		},
+		ActiveJobs:        int32(len(activeJobs)),
+		ActiveJobsPerUser: make(map[string]int32),
+		ActiveJobsPerType: make(map[string]int32),
+	}
+	for runType, max := range s.limits.MaxJobsPerType {
+		resp.Limits.MaxJobsPerType[runType] = int32(max)
+	}
+	for _, job := range activeJobs {
+		resp.ActiveJobsPerUser[job.Labels["user"]]++
+		resp.ActiveJobsPerType[job.Labels["type"]]++
+	}
+	for _, r := range st.start {
+		resp.Queue = append(resp.Queue, &pb.QueuedRun{Run: r.toProto(false)})
+	}
+	for _, r := range st.waiting {
+		resp.Queue = append(resp.Queue, &pb.QueuedRun{Run: r.toProto(false), Reason: st.reasons[r.ID]})
+	}
+	return resp, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sync"
 	pb "terraform-executor/api/proto"
 	"terraform-executor/internal/awsclient"
 	"terraform-executor/internal/k8s"
@@ -14,10 +15,28 @@ type ExecutorService struct {
 	pb.UnimplementedExecutorServer
 	K8sClient *k8s.K8sClient
 	AWSClient *awsclient.AWSClient
-	LogStream *pb.Executor_StreamLogsServer
 	Bucket    string
+	Region    string
 	Debug     bool
+	// Namespace holds the cluster-wide state of the executor, like the scheduler lock
+	Namespace string
 	ctx       context.Context
+	limits    schedulerLimits
+	logs      *logBroker
+
+	// podThresholds bound how long a runner pod may be stuck
+	podThresholds podThresholds
+
+	// store keeps the project files, fileLimits bound their size
+	store      configStore
+	fileLimits fileLimits
+
+	// engineVersions are the versions projects may pin per engine
+	engineVersions map[string][]string
+
+	// runDone tracks the runs executed by this process until they finished
+	runsMu  sync.Mutex
+	runDone map[string]chan struct{}
 }
 
 func NewExecutorService(ctx context.Context) (*ExecutorService, error) {
@@ -40,10 +59,50 @@ func NewExecutorService(ctx context.Context) (*ExecutorService, error) {
 	if bucket == "" {
 		bucket = "uptimeai-test-bucket"
 	}
-	return &ExecutorService{
+	region := awsClient.Region()
+	// fallback to the region used by the state backend
+	if region == "" {
+		region = "eu-west-3"
+	}
+	namespace := os.Getenv("POD_NAMESPACE")
+	// fallback to default namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	limits, err := limitsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	thresholds, err := podThresholdsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	store, err := configStoreFromEnv(k8sClient, awsClient, bucket, region)
+	if err != nil {
+		return nil, err
+	}
+	fileLimits, err := fileLimitsFromEnv(store.Limits())
+	if err != nil {
+		return nil, err
+	}
+	s := &ExecutorService{
 		K8sClient: k8sClient,
 		AWSClient: awsClient,
 		ctx:       ctx,
 		Bucket:    bucket,
-	}, nil
+		Region:    region,
+		Namespace: namespace,
+		limits:    limits,
+		runDone:   make(map[string]chan struct{}),
+		logs:      newLogBroker(),
+
+		podThresholds:  thresholds,
+		store:          store,
+		fileLimits:     fileLimits,
+		engineVersions: engineVersionsFromEnv(),
+	}
+	s.resumeRuns()
+	go s.runQueueLoop()
+	go s.migrateAllSecretVars(ctx)
+	return s, nil
 }
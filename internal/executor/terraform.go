@@ -156,212 +156,185 @@ func (s *ExecutorService) ensureResources(ctx context.Context, userId string) er
 
 // Plan generates a Terraform plan and returns the result.
 func (s *ExecutorService) Plan(ctx context.Context, req *pb.PlanRequest) (*pb.PlanResponse, error) {
-	if err := s.ensureResources(ctx, req.UserId); err != nil {
+	r, err := s.runAndWait(ctx, newRun(req.UserId, req.Project, req.RequestId, "plan"))
+	if err != nil {
 		return &pb.PlanResponse{
 			Success: false,
 			Error:   err.Error(),
 		}, nil
 	}
-
-	jobName := fmt.Sprintf("terraform-plan-%s-%s", req.UserId, time.Now().Format("20060102150405"))
-	job, err := s.createTerraformJobTemplate(ctx, jobName, req.UserId, req.Project, "plan", []string{"plan", "-input=false", "-no-color"})
-	if err != nil {
-		if s.Debug {
-			fmt.Printf("Job template that failed:\n%+v\n", job)
-		}
+	if r.Phase != runPhaseSucceeded {
 		return &pb.PlanResponse{
-			Success: false,
-			Error:   fmt.Sprintf("job creation error: %v", err),
+			Success:     false,
+			Error:       r.Error,
+			PlanOutput:  r.Output,
+			Diagnostics: r.Diagnostics,
 		}, nil
 	}
 
-	_, err = s.K8sClient.CreateJob(ctx, req.UserId, job)
+	result, err := s.loadPlanResult(ctx, r.UserID, r.Project, r.PlanID)
 	if err != nil {
-		if s.Debug {
-			fmt.Printf("Job template that failed:\n%+v\n", job)
-		}
 		return &pb.PlanResponse{
-			Success: false,
-			Error:   fmt.Sprintf("kubernetes job error: %v", err),
+			Success:     false,
+			Error:       fmt.Sprintf("failed to read plan result: %v", err),
+			PlanOutput:  r.Output,
+			PlanId:      r.PlanID,
+			Diagnostics: r.Diagnostics,
 		}, nil
 	}
 
-	// Wait for job completion and get logs
-	output, err := s.waitForJobAndGetLogs(ctx, req.UserId, req.Project, req.RequestId, jobName)
-	if err != nil {
-		return &pb.PlanResponse{
-			Success:    false,
-			Error:      err.Error(),
-			PlanOutput: output,
-		}, nil
+	output := r.Output
+	if result.Text != "" {
+		output = result.Text
 	}
 
 	return &pb.PlanResponse{
-		Success:    true,
-		PlanOutput: output,
+		Success:         true,
+		PlanOutput:      output,
+		PlanId:          r.PlanID,
+		HasChanges:      result.HasChanges,
+		ToAdd:           result.ToAdd,
+		ToChange:        result.ToChange,
+		ToDestroy:       result.ToDestroy,
+		ResourceChanges: result.ResourceChanges,
+		OutputChanges:   result.OutputChanges,
+		Diagnostics:     r.Diagnostics,
 	}, nil
 }
 
 // Apply applies a Terraform plan or configuration and returns the result.
 func (s *ExecutorService) Apply(ctx context.Context, req *pb.ApplyRequest) (*pb.ApplyResponse, error) {
-	if err := s.ensureResources(ctx, req.UserId); err != nil {
-		return &pb.ApplyResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
-	}
-
-	jobName := fmt.Sprintf("terraform-apply-%s", time.Now().Format("20060102150405"))
-	job, err := s.createTerraformJobTemplate(ctx, jobName, req.UserId, req.Project, "apply", []string{"apply", "-auto-approve", "-input=false", "-no-color"})
+	r := newRun(req.UserId, req.Project, req.RequestId, "apply")
+	r.PlanID = req.PlanFile
+	r, err := s.runAndWait(ctx, r)
 	if err != nil {
 		return &pb.ApplyResponse{
 			Success: false,
-			Error:   fmt.Sprintf("failed to create job template: %v", err),
-		}, nil
-	}
-	_, err = s.K8sClient.CreateJob(ctx, req.UserId, job)
-	if err != nil {
-		return &pb.ApplyResponse{
-			Success: false,
-			Error:   fmt.Sprintf("kubernetes job creation failed: %v", err),
+			Error:   err.Error(),
 		}, nil
 	}
-	output, err := s.waitForJobAndGetLogs(ctx, req.UserId, req.Project, req.RequestId, jobName)
-	if err != nil {
+	if r.Phase != runPhaseSucceeded {
 		if s.Debug {
-			fmt.Printf("⚠️ Job execution completed with error: %v\nOutput: %s\n", err, output)
+			fmt.Printf("⚠️ Job execution completed with error: %s\n", s.redactSecrets(ctx, r.UserID, r.Project, fmt.Sprintf("%v\nOutput: %s", r.Error, r.Output)))
 		}
 		return &pb.ApplyResponse{
 			Success:     false,
-			Error:       fmt.Sprintf("job execution failed: %v", err),
-			ApplyOutput: output,
+			Error:       r.Error,
+			ApplyOutput: r.Output,
+			Diagnostics: r.Diagnostics,
 		}, nil
 	}
 	return &pb.ApplyResponse{
 		Success:     true,
-		ApplyOutput: output,
+		ApplyOutput: r.Output,
+		Diagnostics: r.Diagnostics,
 	}, nil
 }
 
 // Destroy destroys the Terraform-managed infrastructure and returns the result.
 func (s *ExecutorService) Destroy(ctx context.Context, req *pb.DestroyRequest) (*pb.DestroyResponse, error) {
-	if err := s.ensureResources(ctx, req.UserId); err != nil {
-		return &pb.DestroyResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
-	}
-
-	jobName := fmt.Sprintf("terraform-destroy-%s", time.Now().Format("20060102150405"))
-	job, err := s.createTerraformJobTemplate(ctx, jobName, req.UserId, req.Project, "destroy", []string{"destroy", "-auto-approve", "-input=false", "-no-color"})
+	r, err := s.runAndWait(ctx, newRun(req.UserId, req.Project, req.RequestId, "destroy"))
 	if err != nil {
 		return &pb.DestroyResponse{Success: false, Error: err.Error()}, nil
 	}
-
-	_, err = s.K8sClient.CreateJob(ctx, req.UserId, job)
-	if err != nil {
-		return &pb.DestroyResponse{
-			Success: false,
-			Error:   fmt.Sprintf("kubernetes job creation failed: %v", err),
-		}, nil
-	}
-
-	// Wait for job completion and get logs
-	output, err := s.waitForJobAndGetLogs(ctx, req.UserId, req.Project, req.RequestId, jobName)
-	if err != nil {
+	if r.Phase != runPhaseSucceeded {
 		return &pb.DestroyResponse{
 			Success:       false,
-			Error:         fmt.Sprintf("job execution failed: %v", err),
-			DestroyOutput: output,
+			Error:         r.Error,
+			DestroyOutput: r.Output,
+			Diagnostics:   r.Diagnostics,
 		}, nil
 	}
 
-	return &pb.DestroyResponse{Success: true, DestroyOutput: output}, nil
+	return &pb.DestroyResponse{Success: true, DestroyOutput: r.Output, Diagnostics: r.Diagnostics}, nil
 }
 
-// GetStateList returns output of "terraform state list" command
-func (s *ExecutorService) GetStateList(ctx context.Context, req *pb.GetStateListRequest) (*pb.GetStateListResponse, error) {
-	if err := s.ensureResources(ctx, req.UserId); err != nil {
-		return &pb.GetStateListResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
+// Validate checks the project configuration with terraform validate. It needs neither AWS credentials
+// nor the backend, an invalid configuration is reported by its errors.
+func (s *ExecutorService) Validate(ctx context.Context, req *pb.ValidateRequest) (*pb.ValidateResponse, error) {
+	r, err := s.runAndWait(ctx, newRun(req.UserId, req.Project, req.RequestId, "validate"))
+	if err != nil {
+		return &pb.ValidateResponse{Success: false, Error: err.Error()}, nil
 	}
 
-	jobName := fmt.Sprintf("terraform-state-list-%s-%s", req.UserId, time.Now().Format("20060102150405"))
-	job, err := s.createTerraformJobTemplate(ctx, jobName, req.UserId, req.Project, "state-list", []string{"state", "list", "-no-color"})
-	if err != nil {
-		return &pb.GetStateListResponse{
-			Success: false,
-			Error:   fmt.Sprintf("job creation error: %v", err),
-		}, nil
+	resp := &pb.ValidateResponse{ValidateOutput: r.Output}
+	for _, d := range r.Diagnostics {
+		if d.Severity == "error" {
+			resp.Errors = append(resp.Errors, d)
+		} else {
+			resp.Warnings = append(resp.Warnings, d)
+		}
+	}
+	// validate exits with 1 for an invalid configuration, a failure without errors is one of the run
+	if r.Phase != runPhaseSucceeded && len(resp.Errors) == 0 {
+		resp.Error = r.Error
+		return resp, nil
 	}
+	resp.Success = true
+	resp.Valid = r.Phase == runPhaseSucceeded
+	return resp, nil
+}
 
-	_, err = s.K8sClient.CreateJob(ctx, req.UserId, job)
+// GetStateList returns output of "terraform state list" command
+func (s *ExecutorService) GetStateList(ctx context.Context, req *pb.GetStateListRequest) (*pb.GetStateListResponse, error) {
+	r, err := s.runAndWait(ctx, newRun(req.UserId, req.Project, req.RequestId, "state-list"))
 	if err != nil {
 		return &pb.GetStateListResponse{
 			Success: false,
-			Error:   fmt.Sprintf("kubernetes job error: %v", err),
+			Error:   err.Error(),
 		}, nil
 	}
-
-	output, err := s.waitForJobAndGetLogs(ctx, req.UserId, req.Project, req.RequestId, jobName)
-	if err != nil {
+	if r.Phase != runPhaseSucceeded {
 		return &pb.GetStateListResponse{
 			Success:         false,
-			Error:           fmt.Sprintf("job execution failed: %v", err),
-			StateListOutput: output,
+			Error:           r.Error,
+			StateListOutput: r.Output,
 		}, nil
 	}
 	return &pb.GetStateListResponse{
 		Success:         true,
-		StateListOutput: output,
+		StateListOutput: r.Output,
 	}, nil
 }
 
+// StreamLogs streams the logs of the runs matching the request filters until the client disconnects.
 func (s *ExecutorService) StreamLogs(req *pb.LogStreamRequest, stream pb.Executor_StreamLogsServer) error {
-	s.LogStream = &stream
+	sub := s.logs.subscribe(req)
+	defer s.logs.unsubscribe(sub)
+
 	ctx := stream.Context()
-	<-ctx.Done() // Wait until the client disconnects
-	return ctx.Err()
+	for {
+		msg, err := sub.next(ctx.Done())
+		if err != nil {
+			return err
+		}
+		if msg == nil {
+			return ctx.Err() // the client disconnected
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
 }
 
 // GetTFShow returns output of "terraform state show" command
 func (s *ExecutorService) GetTFShow(ctx context.Context, req *pb.GetTFShowRequest) (*pb.GetTFShowResponse, error) {
-	if err := s.ensureResources(ctx, req.UserId); err != nil {
-		return &pb.GetTFShowResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, nil
-	}
-
-	jobName := fmt.Sprintf("terraform-show-%s-%s", req.UserId, time.Now().Format("20060102150405"))
-	job, err := s.createTerraformJobTemplate(ctx, jobName, req.UserId, req.Project, "show", []string{"show", "-json", "-no-color"})
-	if err != nil {
-		return &pb.GetTFShowResponse{
-			Success: false,
-			Error:   fmt.Sprintf("job creation error: %v", err),
-		}, nil
-	}
-
-	_, err = s.K8sClient.CreateJob(ctx, req.UserId, job)
+	r, err := s.runAndWait(ctx, newRun(req.UserId, req.Project, req.RequestId, "show"))
 	if err != nil {
 		return &pb.GetTFShowResponse{
 			Success: false,
-			Error:   fmt.Sprintf("kubernetes job error: %v", err),
+			Error:   err.Error(),
 		}, nil
 	}
-
-	output, err := s.waitForJobAndGetLogs(ctx, req.UserId, req.Project, req.RequestId, jobName)
-	if err != nil {
+	if r.Phase != runPhaseSucceeded {
 		return &pb.GetTFShowResponse{
 			Success: false,
-			Error:   fmt.Sprintf("job execution failed: %v", err),
-			Content: output,
+			Error:   r.Error,
+			Content: r.Output,
 		}, nil
 	}
 	return &pb.GetTFShowResponse{
 		Success: true,
-		Content: output,
+		Content: r.Output,
 	}, nil
 }
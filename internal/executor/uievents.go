@@ -0,0 +1,197 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	pb "terraform-executor/api/proto"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// uiMessage is a line of terraform's -json machine readable UI output
+type uiMessage struct {
+	Level      string              `json:"@level"`
+	Message    string              `json:"@message"`
+	Timestamp  time.Time           `json:"@timestamp"`
+	Type       string              `json:"type"`
+	Hook       *uiHook             `json:"hook"`
+	Change     *uiHook             `json:"change"`
+	Diagnostic *uiDiagnostic       `json:"diagnostic"`
+	Changes    *uiChangeSummary    `json:"changes"`
+	Outputs    map[string]uiOutput `json:"outputs"`
+}
+
+type uiHook struct {
+	Resource struct {
+		Addr            string `json:"addr"`
+		Module          string `json:"module"`
+		ResourceType    string `json:"resource_type"`
+		ResourceName    string `json:"resource_name"`
+		ImpliedProvider string `json:"implied_provider"`
+	} `json:"resource"`
+	Action         string `json:"action"`
+	IDKey          string `json:"id_key"`
+	IDValue        string `json:"id_value"`
+	ElapsedSeconds int32  `json:"elapsed_seconds"`
+}
+
+type uiDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Address  string `json:"address"`
+	Range    *struct {
+		Filename string `json:"filename"`
+		Start    struct {
+			Line   int32 `json:"line"`
+			Column int32 `json:"column"`
+		} `json:"start"`
+		End struct {
+			Line   int32 `json:"line"`
+			Column int32 `json:"column"`
+		} `json:"end"`
+	} `json:"range"`
+}
+
+type uiChangeSummary struct {
+	Add       int32  `json:"add"`
+	Change    int32  `json:"change"`
+	Import    int32  `json:"import"`
+	Remove    int32  `json:"remove"`
+	Operation string `json:"operation"`
+}
+
+type uiOutput struct {
+	Sensitive bool            `json:"sensitive"`
+	Type      json.RawMessage `json:"type"`
+	Value     json.RawMessage `json:"value"`
+	Action    string          `json:"action"`
+}
+
+// decodeUIEvent decodes a line of -json UI output, it returns nil for other output like the one of terraform init
+func decodeUIEvent(line string) *pb.RunEvent {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "{") {
+		return nil
+	}
+	var msg uiMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Type == "" {
+		return nil
+	}
+
+	event := &pb.RunEvent{
+		Type:    msg.Type,
+		Level:   msg.Level,
+		Message: msg.Message,
+	}
+	if !msg.Timestamp.IsZero() {
+		event.Timestamp = timestamppb.New(msg.Timestamp)
+	}
+
+	switch {
+	case msg.Hook != nil:
+		event.Resource = msg.Hook.toProto()
+	case msg.Change != nil:
+		// planned_change, resource_drift
+		event.Resource = msg.Change.toProto()
+	case msg.Diagnostic != nil:
+		event.Diagnostic = msg.Diagnostic.toProto()
+	case msg.Changes != nil:
+		event.ChangeSummary = &pb.ChangeSummary{
+			Add:       msg.Changes.Add,
+			Change:    msg.Changes.Change,
+			Import:    msg.Changes.Import,
+			Remove:    msg.Changes.Remove,
+			Operation: msg.Changes.Operation,
+		}
+	case msg.Type == "outputs":
+		event.Outputs = make(map[string]*pb.OutputValue)
+		for name, out := range msg.Outputs {
+			value := &pb.OutputValue{Sensitive: out.Sensitive, Type: rawValue(out.Type), Action: out.Action}
+			// terraform omits sensitive values, never forward them anyway
+			if !out.Sensitive {
+				value.Value = rawValue(out.Value)
+			}
+			event.Outputs[name] = value
+		}
+	}
+	return event
+}
+
+func (h *uiHook) toProto() *pb.ResourceEvent {
+	return &pb.ResourceEvent{
+		Address:        h.Resource.Addr,
+		Module:         h.Resource.Module,
+		ResourceType:   h.Resource.ResourceType,
+		ResourceName:   h.Resource.ResourceName,
+		Provider:       h.Resource.ImpliedProvider,
+		Action:         h.Action,
+		IdKey:          h.IDKey,
+		IdValue:        h.IDValue,
+		ElapsedSeconds: h.ElapsedSeconds,
+	}
+}
+
+func (d *uiDiagnostic) toProto() *pb.Diagnostic {
+	diag := &pb.Diagnostic{
+		Severity: d.Severity,
+		Summary:  d.Summary,
+		Detail:   d.Detail,
+		Address:  d.Address,
+	}
+	if d.Range != nil {
+		diag.Filename = d.Range.Filename
+		diag.StartLine = d.Range.Start.Line
+		diag.StartColumn = d.Range.Start.Column
+		diag.EndLine = d.Range.End.Line
+		diag.EndColumn = d.Range.End.Column
+	}
+	return diag
+}
+
+// renderUIEvent returns the human readable text of an event
+func renderUIEvent(event *pb.RunEvent) string {
+	if d := event.Diagnostic; d != nil {
+		var b strings.Builder
+		b.WriteString(event.Message)
+		if d.Filename != "" {
+			fmt.Fprintf(&b, "\n\n  on %s line %d", d.Filename, d.StartLine)
+		}
+		if d.Detail != "" {
+			b.WriteString("\n\n" + d.Detail)
+		}
+		return b.String()
+	}
+	if event.Type == "outputs" && len(event.Outputs) > 0 {
+		names := make([]string, 0, len(event.Outputs))
+		for name := range event.Outputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var b strings.Builder
+		b.WriteString("Outputs:\n")
+		for _, name := range names {
+			value := event.Outputs[name].Value
+			if event.Outputs[name].Sensitive {
+				value = "<sensitive>"
+			}
+			fmt.Fprintf(&b, "\n%s = %s", name, value)
+		}
+		return b.String()
+	}
+	return event.Message
+}
+
+// renderUIOutput turns -json UI output into human readable text, other lines are kept as they are
+func renderUIOutput(logs string) string {
+	lines := strings.Split(logs, "\n")
+	for i, line := range lines {
+		if event := decodeUIEvent(line); event != nil {
+			lines[i] = renderUIEvent(event)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
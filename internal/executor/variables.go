@@ -0,0 +1,434 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	pb "terraform-executor/api/proto"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// varsFile holds the values of the variables in the working directory, terraform loads it automatically
+const varsFile = "executor.auto.tfvars.json"
+
+// variableNameRegexp matches the names of variables, the identifiers terraform accepts without dashes
+var variableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// varsSecretName returns the name of the Secret holding the values of the variables of a project
+// in its varsFile key, the runner finds it in its working directory
+func varsSecretName(project string) string {
+	return fmt.Sprintf("%s.%s", project, "vars")
+}
+
+// varValues returns the JSON values of the variables held by a Secret
+func varValues(secret *corev1.Secret) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	if data, ok := secret.Data[varsFile]; ok {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("invalid %s in Secret %s: %v", varsFile, secret.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// getVarValues returns the JSON values of the variables of a project
+func (s *ExecutorService) getVarValues(ctx context.Context, namespace, project string) (map[string]json.RawMessage, error) {
+	secret, err := s.K8sClient.GetSecret(ctx, namespace, varsSecretName(project))
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return map[string]json.RawMessage{}, nil
+		}
+		return nil, fmt.Errorf("failed to get Secret: %w", err)
+	}
+	return varValues(secret)
+}
+
+// putVarValues stores the JSON values of variables. Existing values are kept unless overwrite is set.
+func (s *ExecutorService) putVarValues(ctx context.Context, namespace, project string, values map[string]json.RawMessage, overwrite bool) error {
+	secret, err := s.K8sClient.GetSecret(ctx, namespace, varsSecretName(project))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get Secret: %w", err)
+	}
+	create := err != nil
+	merged := make(map[string]json.RawMessage)
+	if create {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: varsSecretName(project)},
+			Type:       corev1.SecretTypeOpaque,
+		}
+	} else if merged, err = varValues(secret); err != nil {
+		return err
+	}
+	for name, value := range values {
+		if _, ok := merged[name]; ok && !overwrite {
+			continue
+		}
+		merged[name] = value
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	secret.Data = map[string][]byte{varsFile: data}
+
+	if create {
+		if err := s.K8sClient.CreateSecret(ctx, namespace, secret); err != nil {
+			return fmt.Errorf("failed to create Secret: %w", err)
+		}
+		return nil
+	}
+	if err := s.K8sClient.UpdateSecret(ctx, namespace, secret); err != nil {
+		return fmt.Errorf("failed to update Secret: %w", err)
+	}
+	return nil
+}
+
+// clearVarValues deletes the values of the variables of a project
+func (s *ExecutorService) clearVarValues(ctx context.Context, namespace, project string) error {
+	if err := s.K8sClient.DeleteSecret(ctx, namespace, varsSecretName(project)); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete Secret: %w", err)
+	}
+	return nil
+}
+
+// parseVariableType parses the type constraint of a variable, string if it is empty
+func parseVariableType(typ string) (cty.Type, error) {
+	if strings.TrimSpace(typ) == "" {
+		return cty.String, nil
+	}
+	expr, diags := hclsyntax.ParseExpression([]byte(typ), "type", hcl.InitialPos)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("invalid type %q: %v", typ, diags.Error())
+	}
+	ty, _, diags := typeexpr.TypeConstraintWithDefaults(expr)
+	if diags.HasErrors() {
+		return cty.NilType, fmt.Errorf("invalid type %q: %v", typ, diags.Error())
+	}
+	return ty, nil
+}
+
+// checkVariableValue checks a JSON value against the type of a variable, it returns the compacted JSON
+func checkVariableValue(ty cty.Type, valueJSON string) (json.RawMessage, error) {
+	raw := []byte(strings.TrimSpace(valueJSON))
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("the value is empty, encode it as JSON like \"text\", 1 or [\"a\"]")
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return nil, fmt.Errorf("the value is no valid JSON: %v", err)
+	}
+	val := cty.NullVal(cty.DynamicPseudoType)
+	if string(raw) != "null" {
+		implied, err := ctyjson.ImpliedType(raw)
+		if err != nil {
+			return nil, fmt.Errorf("the value is no valid JSON: %v", err)
+		}
+		if val, err = ctyjson.Unmarshal(raw, implied); err != nil {
+			return nil, fmt.Errorf("the value is no valid JSON: %v", err)
+		}
+	}
+	if _, err := convert.Convert(val, ty); err != nil {
+		return nil, fmt.Errorf("the value doesn't match type %s: %v", typeexpr.TypeString(ty), err)
+	}
+	return compact.Bytes(), nil
+}
+
+// variableBlock renders the variable block declaring a variable
+func variableBlock(v *pb.Variable) (*hclwrite.Block, error) {
+	typ := strings.TrimSpace(v.Type)
+	if typ == "" {
+		typ = "string"
+	}
+	// the type is written as given, which keeps the defaults of optional attributes
+	f, diags := hclwrite.ParseConfig([]byte(fmt.Sprintf("variable %q {\n  type = %s\n}\n", v.Name, typ)), "variables.tf", hcl.InitialPos)
+	if diags.HasErrors() || len(f.Body().Blocks()) != 1 || len(f.Body().Attributes()) != 0 {
+		return nil, fmt.Errorf("invalid type %q", v.Type)
+	}
+	block := f.Body().Blocks()[0]
+	if v.Description != "" {
+		block.Body().SetAttributeValue("description", cty.StringVal(v.Description))
+	}
+	if v.Sensitive {
+		block.Body().SetAttributeValue("sensitive", cty.True)
+	}
+	return block, nil
+}
+
+// declareVariables declares variables without a default in variables.tf, earlier declarations
+// of the same variables are replaced
+func declareVariables(content string, vars []*pb.Variable) (string, error) {
+	f, diags := hclwrite.ParseConfig([]byte(content), "variables.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("invalid variables.tf: %v", diags.Error())
+	}
+	body := f.Body()
+	for _, v := range vars {
+		for _, b := range body.Blocks() {
+			if b.Type() == "variable" && firstLabel(b) == v.Name {
+				body.RemoveBlock(b)
+			}
+		}
+		block, err := variableBlock(v)
+		if err != nil {
+			return "", err
+		}
+		body.AppendBlock(block)
+	}
+	return string(hclwrite.Format(f.Bytes())), nil
+}
+
+// legacySecretVarRegexp matches a variable block exactly as AddSecretVar wrote it before the values were
+// stored in the Secret, terraform fmt would have aligned the attributes of a block written by hand
+var legacySecretVarRegexp = regexp.MustCompile(`(?m)^variable "([A-Za-z_][A-Za-z0-9_]*)" \{\n  type = string\n  default = (".*")\n\}\n`)
+
+// extractSecretVars removes the values written by AddSecretVar before they were stored in the Secret
+// and marks the variables sensitive, other blocks are left as they are. It returns the new content with
+// the JSON values, none if there is nothing to migrate.
+func extractSecretVars(content string) (string, map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	content = legacySecretVarRegexp.ReplaceAllStringFunc(content, func(block string) string {
+		m := legacySecretVarRegexp.FindStringSubmatch(block)
+		expr, diags := hclsyntax.ParseExpression([]byte(m[2]), "variables.tf", hcl.InitialPos)
+		if diags.HasErrors() {
+			return block
+		}
+		value, diags := expr.Value(nil)
+		if diags.HasErrors() || value.Type() != cty.String || value.IsNull() {
+			return block
+		}
+		encoded, err := json.Marshal(value.AsString())
+		if err != nil {
+			return block
+		}
+		values[m[1]] = encoded
+		return fmt.Sprintf("variable %q {\n  type      = string\n  sensitive = true\n}\n", m[1])
+	})
+	if len(values) == 0 {
+		return content, nil, nil
+	}
+	return content, values, nil
+}
+
+// firstLabel returns the first label of a block, empty if it has none
+func firstLabel(b *hclwrite.Block) string {
+	if labels := b.Labels(); len(labels) > 0 {
+		return labels[0]
+	}
+	return ""
+}
+
+// scrubSecretVars removes the values AddSecretVar wrote into variables.tf in earlier versions from a set
+// of files and returns them, nil if there are none
+func scrubSecretVars(files map[string]string) map[string]json.RawMessage {
+	content, values, err := extractSecretVars(files["variables.tf"])
+	if err != nil || len(values) == 0 {
+		return nil
+	}
+	files["variables.tf"] = content
+	return values
+}
+
+// migrateSecretVars moves the values AddSecretVar wrote into variables.tf in earlier versions to the
+// Secret, it runs in a mutation. The values are stored before variables.tf is rewritten, a failed
+// write leaves the blocks in place and the next call migrates them again.
+func (s *ExecutorService) migrateSecretVars(ctx context.Context, namespace, project string) error {
+	variables, err := s.store.Get(ctx, namespace, project, "variables.tf")
+	if err != nil || !variables.Exists {
+		return err
+	}
+	content, values, err := extractSecretVars(variables.Content)
+	if err != nil || len(values) == 0 {
+		return err
+	}
+	// values set since with SetVariables are kept
+	if err := s.putVarValues(ctx, namespace, project, values, false); err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, namespace, project, "variables.tf", variables, content); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Moved %d variable values of project %s/%s to Secret %s\n", len(values), namespace, project, varsSecretName(project))
+	return nil
+}
+
+// migrateAllSecretVars migrates the variables of every project when the executor starts, a project that
+// is never changed again would keep the values in variables.tf otherwise. Projects are found by the
+// ConfigMap of their variables.tf and by their revisions.
+func (s *ExecutorService) migrateAllSecretVars(ctx context.Context) {
+	cms, err := s.K8sClient.ListConfigMaps(ctx, "", "")
+	if err != nil {
+		fmt.Printf("⚠️ Failed to list projects to migrate their variables: %v\n", err)
+		return
+	}
+	type project struct{ namespace, name string }
+	projects := make(map[project]bool)
+	for _, cm := range cms.Items {
+		if name, ok := strings.CutSuffix(cm.Name, ".variables.tf"); ok {
+			projects[project{cm.Namespace, name}] = true
+		}
+		if cm.Labels["app"] == "terraform-executor" && cm.Labels["component"] == "revision" {
+			projects[project{cm.Namespace, cm.Labels["project"]}] = true
+		}
+	}
+	for p := range projects {
+		// most projects have nothing to migrate, they aren't worth a mutation
+		variables, err := s.store.Get(ctx, p.namespace, p.name, "variables.tf")
+		if err != nil {
+			fmt.Printf("⚠️ Failed to migrate the variables of project %s/%s: %v\n", p.namespace, p.name, err)
+			continue
+		}
+		if _, values, _ := extractSecretVars(variables.Content); len(values) == 0 {
+			continue
+		}
+		_, err = s.mutateProject(ctx, p.namespace, p.name, 0, change{Action: "MigrateSecretVars"}, func() error {
+			return s.migrateSecretVars(ctx, p.namespace, p.name)
+		})
+		if err != nil {
+			fmt.Printf("⚠️ Failed to migrate the variables of project %s/%s: %v\n", p.namespace, p.name, err)
+		}
+	}
+}
+
+// setVariables declares variables in variables.tf and stores their values, it runs in a mutation
+func (s *ExecutorService) setVariables(ctx context.Context, namespace, project string, vars []*pb.Variable, values map[string]json.RawMessage) error {
+	// values written into variables.tf by earlier versions are moved first
+	if err := s.migrateSecretVars(ctx, namespace, project); err != nil {
+		return err
+	}
+	variables, err := s.store.Get(ctx, namespace, project, "variables.tf")
+	if err != nil {
+		return err
+	}
+	content, err := declareVariables(variables.Content, vars)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, namespace, project, "variables.tf", variables, content); err != nil {
+		return err
+	}
+	return s.putVarValues(ctx, namespace, project, values, true)
+}
+
+// checkVariables checks the names, types and values of variables and returns their JSON values
+func checkVariables(vars []*pb.Variable) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage, len(vars))
+	for _, v := range vars {
+		if !variableNameRegexp.MatchString(v.Name) {
+			return nil, fmt.Errorf("invalid variable name %q, must start with a letter or underscore and contain only letters, digits and underscores", v.Name)
+		}
+		if _, ok := values[v.Name]; ok {
+			return nil, fmt.Errorf("variable %s is set twice", v.Name)
+		}
+		ty, err := parseVariableType(v.Type)
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %v", v.Name, err)
+		}
+		if values[v.Name], err = checkVariableValue(ty, v.ValueJson); err != nil {
+			return nil, fmt.Errorf("variable %s: %v", v.Name, err)
+		}
+	}
+	return values, nil
+}
+
+// SetVariables declares typed variables in variables.tf and stores their values. Each value is
+// checked against the type of its variable before anything is written.
+func (s *ExecutorService) SetVariables(ctx context.Context, req *pb.SetVariablesRequest) (*pb.SetVariablesResponse, error) {
+	if err := s.ensureNamespace(ctx, req.UserId); err != nil {
+		return &pb.SetVariablesResponse{Success: false, Error: err.Error()}, nil
+	}
+	values, err := checkVariables(req.Variables)
+	if err != nil {
+		return &pb.SetVariablesResponse{Success: false, Error: err.Error()}, nil
+	}
+
+	revision, err := s.mutateProject(ctx, req.UserId, req.Project, req.ExpectedRevision, change{RequestID: req.RequestId, Author: req.Author, Action: "SetVariables"}, func() error {
+		return s.setVariables(ctx, req.UserId, req.Project, req.Variables, values)
+	})
+	if err != nil {
+		return &pb.SetVariablesResponse{Success: false, Error: err.Error()}, mutationStatus(err)
+	}
+	return &pb.SetVariablesResponse{Success: true, Revision: revision}, nil
+}
+
+// sensitiveVariable reports whether a variable block is marked sensitive
+func sensitiveVariable(b *hclsyntax.Block) bool {
+	attr, ok := b.Body.Attributes["sensitive"]
+	if !ok {
+		return false
+	}
+	val, diags := attr.Expr.Value(nil)
+	return !diags.HasErrors() && val.Type() == cty.Bool && !val.IsNull() && val.True()
+}
+
+// sensitiveVariables returns the names of the variables declared sensitive in variables.tf
+func sensitiveVariables(content string) map[string]bool {
+	names := make(map[string]bool)
+	parsed, diags := hclsyntax.ParseConfig([]byte(content), "variables.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return names
+	}
+	for _, b := range parsed.Body.(*hclsyntax.Body).Blocks {
+		if b.Type == "variable" && len(b.Labels) == 1 && sensitiveVariable(b) {
+			names[b.Labels[0]] = true
+		}
+	}
+	return names
+}
+
+// ListVariables lists the variables declared in variables.tf with the values of those that aren't sensitive.
+func (s *ExecutorService) ListVariables(ctx context.Context, req *pb.ListVariablesRequest) (*pb.ListVariablesResponse, error) {
+	variables, err := s.store.Get(ctx, req.UserId, req.Project, "variables.tf")
+	if err != nil {
+		return &pb.ListVariablesResponse{Success: false, Error: err.Error()}, nil
+	}
+	values, err := s.getVarValues(ctx, req.UserId, req.Project)
+	if err != nil {
+		return &pb.ListVariablesResponse{Success: false, Error: err.Error()}, nil
+	}
+	src := []byte(variables.Content)
+	parsed, diags := hclsyntax.ParseConfig(src, "variables.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return &pb.ListVariablesResponse{Success: false, Error: fmt.Sprintf("invalid variables.tf: %v", diags.Error())}, nil
+	}
+
+	resp := &pb.ListVariablesResponse{Success: true}
+	for _, b := range parsed.Body.(*hclsyntax.Body).Blocks {
+		if b.Type != "variable" || len(b.Labels) != 1 {
+			continue
+		}
+		v := &pb.Variable{Name: b.Labels[0], Type: "any"}
+		if attr, ok := b.Body.Attributes["type"]; ok {
+			v.Type = string(attr.Expr.Range().SliceBytes(src))
+		}
+		if attr, ok := b.Body.Attributes["description"]; ok {
+			if val, diags := attr.Expr.Value(nil); !diags.HasErrors() && val.Type() == cty.String && !val.IsNull() {
+				v.Description = val.AsString()
+			}
+		}
+		v.Sensitive = sensitiveVariable(b)
+		value, ok := values[v.Name]
+		v.HasValue = ok
+		if ok && !v.Sensitive {
+			v.ValueJson = string(value)
+		}
+		resp.Variables = append(resp.Variables, v)
+	}
+	sort.Slice(resp.Variables, func(i, j int) bool {
+		return resp.Variables[i].Name < resp.Variables[j].Name
+	})
+	return resp, nil
+}
@@ -13,6 +13,7 @@ import (
 
 type K8sClient struct {
 	clientset *kubernetes.Clientset
+	config    *rest.Config
 }
 
 func NewK8sClient(kubeconfigPath string) (*K8sClient, error) {
@@ -47,6 +48,7 @@ func NewK8sClient(kubeconfigPath string) (*K8sClient, error) {
 
 	return &K8sClient{
 		clientset: clientset,
+		config:    config,
 	}, nil
 }
 
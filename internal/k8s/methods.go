@@ -12,6 +12,8 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
 )
 
 // NamespaceExists checks if a namespace exists
@@ -59,26 +61,48 @@ func (c *K8sClient) UpdateConfigMap(ctx context.Context, namespace string, cm *c
 	return err
 }
 
+// ListConfigMaps lists ConfigMaps matching the label selector, an empty namespace lists across all namespaces
+func (c *K8sClient) ListConfigMaps(ctx context.Context, namespace, labelSelector string) (*corev1.ConfigMapList, error) {
+	return c.clientset.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+}
+
 // DeleteConfigMap deletes a ConfigMap from the specified namespace
 func (c *K8sClient) DeleteConfigMap(ctx context.Context, namespace, name string) error {
 	return c.clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, name, metav1.DeleteOptions{})
 }
 
+// DeleteConfigMapVersion deletes a ConfigMap unless it changed since the given resource version
+func (c *K8sClient) DeleteConfigMapVersion(ctx context.Context, namespace, name, resourceVersion string) error {
+	return c.clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &resourceVersion},
+	})
+}
+
 // GetSecret retrieves a Secret from the specified namespace
 func (c *K8sClient) GetSecret(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
 	return c.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
-// CreateSecret creates a new Secret in the specified namespace
+// CreateSecret creates a new Secret in the specified namespace, secret is set to the created one
 func (c *K8sClient) CreateSecret(ctx context.Context, namespace string, secret *corev1.Secret) error {
-	_, err := c.clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
-	return err
+	created, err := c.clientset.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	*secret = *created
+	return nil
 }
 
-// UpdateSecret updates an existing Secret in the specified namespace
+// UpdateSecret updates an existing Secret in the specified namespace, secret is set to the updated one
 func (c *K8sClient) UpdateSecret(ctx context.Context, namespace string, secret *corev1.Secret) error {
-	_, err := c.clientset.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
-	return err
+	updated, err := c.clientset.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	*secret = *updated
+	return nil
 }
 
 // DeleteSecret deletes a Secret from the specified namespace
@@ -122,9 +146,19 @@ func (c *K8sClient) GetJob(ctx context.Context, namespace, name string) (*batchv
 	return c.clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
-// DeleteJob deletes a Job from the specified namespace
+// ListJobs lists Jobs matching the label selector, an empty namespace lists across all namespaces
+func (c *K8sClient) ListJobs(ctx context.Context, namespace, labelSelector string) (*batchv1.JobList, error) {
+	return c.clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+}
+
+// DeleteJob deletes a Job and, with foreground propagation, its pods from the specified namespace
 func (c *K8sClient) DeleteJob(ctx context.Context, namespace, name string) error {
-	return c.clientset.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	propagation := metav1.DeletePropagationForeground
+	return c.clientset.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
 }
 
 // WatchJob watches for job events in the specified namespace
@@ -175,6 +209,58 @@ func (c *K8sClient) GetPodLogs(ctx context.Context, namespace, podName, containe
 	return buf.String(), nil
 }
 
+// FollowPodLogs opens a follow-mode stream of a container's logs, each line is prefixed with its RFC3339 timestamp.
+// sinceTime resumes a stream after a disconnect, the API server applies it with a precision of seconds.
+func (c *K8sClient) FollowPodLogs(ctx context.Context, namespace, podName, containerName string, sinceTime *metav1.Time) (io.ReadCloser, error) {
+	req := c.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1.PodLogOptions{
+		Container:  containerName,
+		Follow:     true,
+		Timestamps: true,
+		SinceTime:  sinceTime,
+	})
+	return req.Stream(ctx)
+}
+
+// GetPod returns a pod
+func (c *K8sClient) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	return c.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// ListEvents lists the events of a namespace matching a field selector, e.g. involvedObject.name=<pod>
+func (c *K8sClient) ListEvents(ctx context.Context, namespace, fieldSelector string) (*corev1.EventList, error) {
+	return c.clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: fieldSelector})
+}
+
+// ExecInPod runs a command in a container of a pod and returns its output
+func (c *K8sClient) ExecInPod(ctx context.Context, namespace, podName, containerName string, command []string) (string, error) {
+	req := c.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(c.config, "POST", req.URL())
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return stdout.String(), fmt.Errorf("%v: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
 // ListPods lists all pods in namespace matching the label selector
 func (c *K8sClient) ListPods(ctx context.Context, namespace string, labelSelector string) (*corev1.PodList, error) {
 	fmt.Printf("🔍 Listing pods in namespace=%s with labels=%s\n", namespace, labelSelector)
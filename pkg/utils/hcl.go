@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// CheckHCL parses a configuration file and returns its syntax errors
+func CheckHCL(filename string, src []byte) hcl.Diagnostics {
+	_, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	return diags
+}
+
+// BlockAddress returns the address of a top-level block, like aws_s3_bucket.x, data.aws_ami.x or module.x.
+// It is empty for blocks that may be repeated, like provider or locals.
+func BlockAddress(blockType string, labels []string) string {
+	switch {
+	case blockType == "resource" && len(labels) == 2:
+		return labels[0] + "." + labels[1]
+	case blockType == "data" && len(labels) == 2:
+		return "data." + labels[0] + "." + labels[1]
+	case blockType == "module" && len(labels) == 1:
+		return "module." + labels[0]
+	case blockType == "variable" && len(labels) == 1:
+		return "var." + labels[0]
+	case blockType == "output" && len(labels) == 1:
+		return "output." + labels[0]
+	}
+	return ""
+}
+
+// DuplicateBlocks parses a configuration file and returns an error for each block declaring an address declared before
+func DuplicateBlocks(filename string, src []byte) hcl.Diagnostics {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return diags
+	}
+	seen := make(map[string]*hclsyntax.Block)
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		address := BlockAddress(block.Type, block.Labels)
+		if address == "" {
+			continue
+		}
+		if first, ok := seen[address]; ok {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Duplicate %s block", block.Type),
+				Detail:   fmt.Sprintf("%s was already declared at %s.", address, first.DefRange()),
+				Subject:  block.DefRange().Ptr(),
+			})
+			continue
+		}
+		seen[address] = block
+	}
+	return diags
+}
+
+// Block is a top-level block of a configuration file
+type Block struct {
+	Type    string
+	Labels  []string
+	Address string // BlockAddress, or the type and labels joined by dots for blocks that may be repeated
+	Range   hcl.Range
+}
+
+// ParseBlocks returns the top-level blocks of a configuration file in their order
+func ParseBlocks(filename string, src []byte) ([]Block, hcl.Diagnostics) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body := file.Body.(*hclsyntax.Body)
+	blocks := make([]Block, 0, len(body.Blocks))
+	for _, b := range body.Blocks {
+		address := BlockAddress(b.Type, b.Labels)
+		if address == "" {
+			address = strings.Join(append([]string{b.Type}, b.Labels...), ".")
+		}
+		blocks = append(blocks, Block{Type: b.Type, Labels: b.Labels, Address: address, Range: b.Range()})
+	}
+	return blocks, diags
+}
+
+// Source returns the source of a block of the file it was parsed from
+func (b Block) Source(src []byte) string {
+	return string(src[b.Range.Start.Byte:b.Range.End.Byte])
+}
+
+// ReplaceBlock replaces the source of a block, the rest of the file is kept as it is
+func ReplaceBlock(src []byte, b Block, code string) []byte {
+	out := append([]byte{}, src[:b.Range.Start.Byte]...)
+	out = append(out, strings.TrimSpace(code)...)
+	return append(out, src[b.Range.End.Byte:]...)
+}
+
+// RemoveBlock removes a block with its line break, and the blank line separating it from the next block.
+// Comments preceding the block are kept.
+func RemoveBlock(src []byte, b Block) []byte {
+	start, end := b.Range.Start.Byte, b.Range.End.Byte
+	if end < len(src) && src[end] == '\n' {
+		end++
+	}
+	if end < len(src) && src[end] == '\n' && (start == 0 || (start >= 2 && src[start-1] == '\n' && src[start-2] == '\n')) {
+		end++
+	}
+	out := append(append([]byte{}, src[:start]...), src[end:]...)
+	if end == len(src) {
+		// the last block was removed, drop the blank line that separated it
+		out = []byte(strings.TrimRight(string(out), "\n"))
+		if len(out) > 0 {
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
+
+// AppendBlock appends the source of a block, separated from the previous block by a blank line
+func AppendBlock(src []byte, code string) []byte {
+	content := strings.TrimRight(string(src), "\n")
+	if content != "" {
+		content += "\n\n"
+	}
+	return []byte(content + strings.TrimSpace(code) + "\n")
+}
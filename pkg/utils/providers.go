@@ -2,6 +2,8 @@ package utils
 
 import (
 	"bytes"
+	"fmt"
+	"regexp"
 	"text/template"
 )
 
@@ -16,10 +18,15 @@ type TerraformTemplateData struct {
 	UserID    string
 	Project   string
 	Providers []ProviderConfig
+	// RequiredVersion is the required_version constraint, omitted if empty
+	RequiredVersion string
 }
 
 const terraformTemplate = `
 terraform {
+{{- if .RequiredVersion }}
+    required_version = "{{ .RequiredVersion }}"
+{{- end }}
     backend "s3" {
         bucket  = "{{ .Bucket }}"
 		key     = "{{ .UserID }}/{{ .Project }}/terraform.tfstate"
@@ -51,3 +58,21 @@ func GenerateTerraformConfig(data TerraformTemplateData) (string, error) {
 
 	return buf.String(), nil
 }
+
+var (
+	requiredVersionRegexp = regexp.MustCompile(`(?m)^[ \t]*required_version[ \t]*=.*\n?`)
+	terraformBlockRegexp  = regexp.MustCompile(`(?m)^terraform[ \t]*\{[ \t]*\n`)
+)
+
+// SetRequiredVersion replaces the required_version of a rendered configuration, an empty constraint removes it
+func SetRequiredVersion(config, constraint string) string {
+	config = requiredVersionRegexp.ReplaceAllString(config, "")
+	if constraint == "" {
+		return config
+	}
+	loc := terraformBlockRegexp.FindStringIndex(config)
+	if loc == nil {
+		return config
+	}
+	return config[:loc[1]] + fmt.Sprintf("    required_version = %q\n", constraint) + config[loc[1]:]
+}